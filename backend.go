@@ -0,0 +1,132 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Backend evaluates a limiter strategy against state shared by every replica
+// of a service. Implementations must honor ctx cancellation so the route
+// timeout can be enforced.
+type Backend interface {
+	Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error)
+}
+
+type FailurePolicy string
+
+const (
+	FailurePolicyOpen   FailurePolicy = "fail_open"
+	FailurePolicyClosed FailurePolicy = "fail_closed"
+	FailurePolicyLocal  FailurePolicy = "local"
+)
+
+const (
+	defaultBackendTimeout          = 100 * time.Millisecond
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenInterval     = 5 * time.Second
+)
+
+type BackendDescriptor struct {
+	Name           string                    `json:"name" yaml:"name"`
+	FailurePolicy  FailurePolicy             `json:"failure_policy,omitempty" yaml:"failure_policy,omitempty"`
+	Timeout        float64                   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Replicas       int                       `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	CircuitBreaker *CircuitBreakerDescriptor `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty"`
+	Sync           *BackendSyncDescriptor    `json:"sync,omitempty" yaml:"sync,omitempty"`
+}
+
+type CircuitBreakerDescriptor struct {
+	FailureThreshold int     `json:"failure_threshold" yaml:"failure_threshold"`
+	OpenInterval     float64 `json:"open_interval" yaml:"open_interval"`
+}
+
+// BackendSyncDescriptor switches a route to approximate limiting: decisions
+// are taken locally and reconciled with the backend every interval.
+type BackendSyncDescriptor struct {
+	Interval     float64 `json:"interval" yaml:"interval"`
+	MaxOvershoot float64 `json:"max_overshoot" yaml:"max_overshoot"`
+}
+
+// newRemoteRateLimiterFactory validates a backend descriptor once and returns
+// a constructor of limiters for keys of the backend. Their limiters share the
+// circuit breaker and, in sync mode, the sync loop of the descriptor.
+func newRemoteRateLimiterFactory(strategy StrategyDescriptor, descriptor BackendDescriptor, backends map[string]Backend, closeSign <-chan struct{}) (func(key string) iRateLimiter, error) {
+	backend, exists := backends[descriptor.Name]
+	if !exists {
+		return nil, fmt.Errorf("unknown backend: %s", descriptor.Name)
+	}
+
+	policy := descriptor.FailurePolicy
+	if policy == "" {
+		policy = FailurePolicyOpen
+	}
+
+	newLocal := func() iRateLimiter { return nil }
+	switch policy {
+	case FailurePolicyOpen, FailurePolicyClosed:
+	case FailurePolicyLocal:
+		replicas := max(descriptor.Replicas, 1)
+		factory, err := newRateLimiterFactory(scaleStrategyDescriptor(strategy, 1/float64(replicas)))
+		if err != nil {
+			return nil, err
+		}
+		newLocal = factory
+	default:
+		return nil, fmt.Errorf("unknown failure policy: %s", policy)
+	}
+
+	timeout := defaultBackendTimeout
+	if descriptor.Timeout > 0 {
+		timeout = time.Duration(descriptor.Timeout * float64(time.Second))
+	}
+
+	failureThreshold := defaultBreakerFailureThreshold
+	openInterval := defaultBreakerOpenInterval
+	if descriptor.CircuitBreaker != nil {
+		if descriptor.CircuitBreaker.FailureThreshold <= 0 || descriptor.CircuitBreaker.OpenInterval <= 0 {
+			return nil, errors.New("invalid circuit_breaker parameters")
+		}
+		failureThreshold = descriptor.CircuitBreaker.FailureThreshold
+		openInterval = time.Duration(descriptor.CircuitBreaker.OpenInterval * float64(time.Second))
+	}
+
+	breaker := newCircuitBreaker(failureThreshold, openInterval)
+
+	if descriptor.Sync != nil {
+		if descriptor.Sync.Interval <= 0 || descriptor.Sync.MaxOvershoot < 1 {
+			return nil, errors.New("invalid sync parameters")
+		}
+		group := newBatchedSyncGroup(time.Duration(descriptor.Sync.Interval*float64(time.Second)), closeSign)
+		return func(key string) iRateLimiter {
+			fallback := failureFallback{policy: policy, local: newLocal()}
+			return newBatchedRemoteRateLimiter(backend, key, strategy, timeout, fallback, breaker, descriptor.Sync.MaxOvershoot, group)
+		}, nil
+	}
+	return func(key string) iRateLimiter {
+		fallback := failureFallback{policy: policy, local: newLocal()}
+		return newRemoteRateLimiter(backend, key, strategy, timeout, fallback, breaker)
+	}, nil
+}
+
+// scaleStrategyDescriptor returns a copy of the descriptor with its capacity
+// and refill rate multiplied by factor. Capacity is rounded up so that a share
+// is never empty.
+func scaleStrategyDescriptor(strategy StrategyDescriptor, factor float64) StrategyDescriptor {
+	params := make(map[string]any, len(strategy.Params))
+	for key, value := range strategy.Params {
+		params[key] = value
+	}
+	if capacity, ok := getNumberFromMap[float64](strategy.Params, "capacity"); ok {
+		params["capacity"] = math.Ceil(capacity * factor)
+	}
+	if refillRate, ok := getNumberFromMap[float64](strategy.Params, "refill_rate"); ok {
+		params["refill_rate"] = refillRate * factor
+	}
+	return StrategyDescriptor{
+		StrategyName: strategy.StrategyName,
+		Params:       params,
+	}
+}
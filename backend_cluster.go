@@ -0,0 +1,149 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ClusterTakePath is the endpoint peers use to forward decisions to the owner
+// of a key.
+const ClusterTakePath = "/rate-limiter/v1/take"
+
+type clusterTakeRequest struct {
+	Key      string             `json:"key"`
+	Strategy StrategyDescriptor `json:"strategy"`
+	Cost     float64            `json:"cost"`
+}
+
+type clusterTakeResponse struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ClusterBackend shares limits between nodes without an external store. Each
+// key is owned by one node, chosen by consistent hashing over the peer list,
+// and every other node forwards decisions for that key to its owner over
+// HTTP. Nodes are identified by their base URL, and each node must serve
+// Handler at ClusterTakePath.
+//
+// When membership changes, keys that move to a new owner start from a fresh
+// bucket on that node.
+type ClusterBackend struct {
+	self   string
+	client *http.Client
+	local  *MemoryBackend
+	ring   *hashRing
+	mutex  sync.RWMutex
+}
+
+func NewClusterBackend(self string, peers []string, client *http.Client) *ClusterBackend {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cluster := &ClusterBackend{
+		self:   strings.TrimRight(self, "/"),
+		client: client,
+		local:  NewMemoryBackend(),
+		mutex:  sync.RWMutex{},
+	}
+	cluster.SetPeers(peers)
+	return cluster
+}
+
+// SetPeers replaces the cluster membership and rehashes key ownership. The
+// local node is always part of the ring.
+func (c *ClusterBackend) SetPeers(peers []string) {
+	nodes := []string{c.self}
+	for _, peer := range peers {
+		peer = strings.TrimRight(peer, "/")
+		if peer != c.self {
+			nodes = append(nodes, peer)
+		}
+	}
+	ring := newHashRing(nodes)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ring = ring
+}
+
+func (c *ClusterBackend) owner(key string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	owner, _ := c.ring.owner(key)
+	return owner
+}
+
+func (c *ClusterBackend) Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error) {
+	owner := c.owner(key)
+	if owner == c.self {
+		return c.local.Take(ctx, key, strategy, cost)
+	}
+	return c.forward(ctx, owner, clusterTakeRequest{Key: key, Strategy: strategy, Cost: cost})
+}
+
+func (c *ClusterBackend) forward(ctx context.Context, owner string, takeRequest clusterTakeRequest) (bool, error) {
+	body, err := json.Marshal(takeRequest)
+	if err != nil {
+		return false, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, owner+ClusterTakePath, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	var takeResponse clusterTakeResponse
+	if err := json.NewDecoder(response.Body).Decode(&takeResponse); err != nil {
+		return false, fmt.Errorf("invalid response from peer %s: %w", owner, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("peer %s: %s", owner, takeResponse.Error)
+	}
+	return takeResponse.Allowed, nil
+}
+
+// Handler answers decisions forwarded by peers. Forwarded requests are always
+// evaluated locally, even if this node's view of the ring disagrees, so that
+// nodes with different membership never forward in a loop.
+func (c *ClusterBackend) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(clusterTakeResponse{Error: "method not allowed"})
+			return
+		}
+
+		var takeRequest clusterTakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&takeRequest); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(clusterTakeResponse{Error: err.Error()})
+			return
+		}
+		if takeRequest.Key == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(clusterTakeResponse{Error: "missing key"})
+			return
+		}
+
+		allowed, err := c.local.Take(r.Context(), takeRequest.Key, takeRequest.Strategy, takeRequest.Cost)
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(clusterTakeResponse{Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(clusterTakeResponse{Allowed: allowed})
+	})
+}
@@ -0,0 +1,106 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newTestCluster(t *testing.T, size int) ([]*ClusterBackend, []string) {
+	t.Helper()
+	backends := make([]*ClusterBackend, size)
+	urls := make([]string, size)
+	for i := range size {
+		index := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			backends[index].Handler().ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	for i := range size {
+		backends[i] = NewClusterBackend(urls[i], urls, nil)
+	}
+	return backends, urls
+}
+
+func TestClusterBackend_SharesLimitAcrossNodes(t *testing.T) {
+	backends, _ := newTestCluster(t, 3)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 6, "reset_interval": 60.0},
+	}
+
+	// Spread requests for the same key over every node
+	allowedCount := 0
+	for i := 0; i < 12; i++ {
+		allowed, err := backends[i%3].Take(context.Background(), "/api:client", strategy, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if allowed {
+			allowedCount++
+		}
+	}
+	if allowedCount != 6 {
+		t.Errorf("Expected 6 requests allowed cluster wide, got %d", allowedCount)
+	}
+}
+
+func TestClusterBackend_OwnersAgree(t *testing.T) {
+	backends, _ := newTestCluster(t, 3)
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		owner := backends[0].owner(key)
+		for _, backend := range backends[1:] {
+			if backend.owner(key) != owner {
+				t.Fatalf("Nodes disagree on the owner of %s", key)
+			}
+		}
+	}
+}
+
+func TestClusterBackend_SetPeersRehashes(t *testing.T) {
+	backends, urls := newTestCluster(t, 3)
+
+	// Drop the third node from every member's view
+	for _, backend := range backends[:2] {
+		backend.SetPeers(urls[:2])
+	}
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if owner := backends[0].owner(key); owner == urls[2] {
+			t.Fatalf("Key %s still owned by removed node", key)
+		}
+	}
+
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyTokenBucket,
+		Params:       map[string]any{"capacity": 1, "refill_rate": 0, "request_cost": 1},
+	}
+	if allowed, err := backends[1].Take(context.Background(), "key", strategy, 1); err != nil || !allowed {
+		t.Errorf("Expected request to be allowed after rehash, got %v (%v)", allowed, err)
+	}
+}
+
+func TestClusterBackend_UnreachableOwner(t *testing.T) {
+	backends, urls := newTestCluster(t, 2)
+	backends[0].SetPeers([]string{urls[0], "http://127.0.0.1:1"})
+
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 1, "reset_interval": 60.0},
+	}
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if backends[0].owner(key) == "http://127.0.0.1:1" {
+			if _, err := backends[0].Take(context.Background(), key, strategy, 1); err == nil {
+				t.Error("Expected an error when the owner is unreachable")
+			}
+			return
+		}
+	}
+	t.Fatal("Expected some key to be owned by the unreachable peer")
+}
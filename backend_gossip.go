@@ -0,0 +1,235 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GossipPath is the endpoint peers push their counters to.
+const GossipPath = "/rate-limiter/v1/gossip"
+
+const gossipFanout = 3
+
+// defaultGossipInterval is used when NewGossipBackend is given an interval
+// that is not positive.
+const defaultGossipInterval = time.Second
+
+type gossipEntry struct {
+	Counter   pnCounter `json:"counter"`
+	ExpiresAt int64     `json:"expires_at"`
+}
+
+type gossipMessage struct {
+	Node    string                 `json:"node"`
+	Entries map[string]gossipEntry `json:"entries"`
+}
+
+// GossipBackend answers every decision locally from per key and window
+// counters that are replicated to peers as CRDTs. Counts converge within a few
+// gossip intervals, so limits are eventually consistent: during that time the
+// cluster can admit more than the configured capacity. It suits coarse limits
+// where availability matters more than exactness.
+//
+// Supported strategies are fixed_window and sliding_window_counter. A negative
+// cost refunds capacity to the current window.
+type GossipBackend struct {
+	self        string
+	peers       []string
+	client      *http.Client
+	entries     map[string]*gossipEntry
+	mutex       sync.Mutex
+	ticker      *time.Ticker
+	closeSignal <-chan struct{}
+}
+
+func NewGossipBackend(self string, peers []string, interval time.Duration, client *http.Client, closeSignal <-chan struct{}) *GossipBackend {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if interval <= 0 {
+		interval = defaultGossipInterval
+	}
+	gossip := &GossipBackend{
+		self:        strings.TrimRight(self, "/"),
+		client:      client,
+		entries:     make(map[string]*gossipEntry),
+		mutex:       sync.Mutex{},
+		ticker:      time.NewTicker(interval),
+		closeSignal: closeSignal,
+	}
+	gossip.SetPeers(peers)
+	go func(gossip *GossipBackend) {
+		for {
+			select {
+			case <-gossip.ticker.C:
+				gossip.gossip(context.Background())
+			case <-gossip.closeSignal:
+				gossip.ticker.Stop()
+				return
+			}
+		}
+	}(gossip)
+	return gossip
+}
+
+func (g *GossipBackend) SetPeers(peers []string) {
+	others := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		peer = strings.TrimRight(peer, "/")
+		if peer != g.self {
+			others = append(others, peer)
+		}
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.peers = others
+}
+
+func (g *GossipBackend) Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error) {
+	now := time.Now().UnixNano()
+
+	switch strategy.StrategyName {
+	case LimiterStrategyFixedWindow:
+		params, err := GetFixedWindowRateLimiterParamsFromMap(strategy.Params)
+		if err != nil {
+			return false, err
+		}
+		if params.ResetInterval <= 0 {
+			return false, fmt.Errorf("invalid reset_interval parameter")
+		}
+		window := now / int64(params.ResetInterval)
+
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		current := g.entry(key, window, params.ResetInterval)
+		if cost > 0 && current.Counter.value()+cost > float64(params.Capacity) {
+			return false, nil
+		}
+		current.Counter.add(g.self, cost)
+		return true, nil
+	case LimiterStrategySlidingWindowCounter:
+		params, err := getSlidingWindowCounterLimiterParamsFromMap(strategy.Params)
+		if err != nil {
+			return false, err
+		}
+		window := now / int64(params.WindowSize)
+
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		current := g.entry(key, window, params.WindowSize)
+		previous := g.entry(key, window-1, params.WindowSize)
+		estimate := slidingWindowEstimate(previous.Counter.value(), current.Counter.value(), now, params.WindowSize)
+		if cost > 0 && estimate+cost > float64(params.Capacity) {
+			return false, nil
+		}
+		current.Counter.add(g.self, cost)
+		return true, nil
+	default:
+		return false, fmt.Errorf("gossip backend does not support strategy: %s", strategy.StrategyName)
+	}
+}
+
+// entry returns the counter of key for the given window, creating it if
+// needed. Entries are kept until the following window is over, since sliding
+// windows still read them. Must be called with the mutex held.
+func (g *GossipBackend) entry(key string, window int64, windowSize time.Duration) *gossipEntry {
+	entryKey := key + "@" + strconv.FormatInt(window, 10)
+	if entry, exists := g.entries[entryKey]; exists {
+		return entry
+	}
+	entry := &gossipEntry{
+		Counter:   newPNCounter(),
+		ExpiresAt: (window + 2) * int64(windowSize),
+	}
+	g.entries[entryKey] = entry
+	return entry
+}
+
+// gossip drops expired counters and pushes the remaining ones to a random
+// subset of peers. Delivery failures are ignored; the next round resends the
+// full state.
+func (g *GossipBackend) gossip(ctx context.Context) {
+	now := time.Now().UnixNano()
+
+	g.mutex.Lock()
+	message := gossipMessage{
+		Node:    g.self,
+		Entries: make(map[string]gossipEntry, len(g.entries)),
+	}
+	for entryKey, entry := range g.entries {
+		if entry.ExpiresAt <= now {
+			delete(g.entries, entryKey)
+			continue
+		}
+		message.Entries[entryKey] = gossipEntry{Counter: entry.Counter.clone(), ExpiresAt: entry.ExpiresAt}
+	}
+	peers := make([]string, len(g.peers))
+	copy(peers, g.peers)
+	g.mutex.Unlock()
+
+	if len(message.Entries) == 0 {
+		return
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	for _, peer := range peers[:min(gossipFanout, len(peers))] {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, peer+GossipPath, bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		request.Header.Set("Content-Type", "application/json")
+		response, err := g.client.Do(request)
+		if err != nil {
+			continue
+		}
+		response.Body.Close()
+	}
+}
+
+func (g *GossipBackend) merge(message gossipMessage) {
+	now := time.Now().UnixNano()
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for entryKey, remote := range message.Entries {
+		if remote.ExpiresAt <= now {
+			continue
+		}
+		local, exists := g.entries[entryKey]
+		if !exists {
+			local = &gossipEntry{Counter: newPNCounter(), ExpiresAt: remote.ExpiresAt}
+			g.entries[entryKey] = local
+		}
+		local.Counter.merge(remote.Counter)
+	}
+}
+
+// Handler receives counters pushed by peers.
+func (g *GossipBackend) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var message gossipMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g.merge(message)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,128 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestGossipCluster(t *testing.T, size int, interval time.Duration) []*GossipBackend {
+	t.Helper()
+	closeChan := make(chan struct{})
+	t.Cleanup(func() { close(closeChan) })
+
+	backends := make([]*GossipBackend, size)
+	urls := make([]string, size)
+	for i := range size {
+		index := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			backends[index].Handler().ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	for i := range size {
+		backends[i] = NewGossipBackend(urls[i], urls, interval, nil, closeChan)
+	}
+	return backends
+}
+
+func TestGossipBackend_FixedWindowConverges(t *testing.T) {
+	backends := newTestGossipCluster(t, 3, time.Hour)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 4, "reset_interval": 3600.0},
+	}
+
+	for i := 0; i < 4; i++ {
+		if allowed, _ := backends[0].Take(context.Background(), "abuse:1.2.3.4", strategy, 1); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	// Before gossip the other replicas still answer from their own view
+	if allowed, _ := backends[1].Take(context.Background(), "abuse:1.2.3.4", strategy, 1); !allowed {
+		t.Error("Expected replica to answer locally before gossip")
+	}
+
+	for _, backend := range backends {
+		backend.gossip(context.Background())
+	}
+	for _, backend := range backends {
+		backend.gossip(context.Background())
+	}
+
+	for i, backend := range backends {
+		if allowed, _ := backend.Take(context.Background(), "abuse:1.2.3.4", strategy, 1); allowed {
+			t.Errorf("Expected replica %d to see the global count after gossip", i)
+		}
+	}
+}
+
+func TestGossipBackend_SlidingWindowCounter(t *testing.T) {
+	backends := newTestGossipCluster(t, 2, time.Hour)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategySlidingWindowCounter,
+		Params:       map[string]any{"capacity": 2, "window_size": 3600.0},
+	}
+
+	backends[0].Take(context.Background(), "key", strategy, 2)
+	backends[0].gossip(context.Background())
+
+	if allowed, _ := backends[1].Take(context.Background(), "key", strategy, 1); allowed {
+		t.Error("Expected the peer's requests to count after gossip")
+	}
+}
+
+func TestGossipBackend_BackgroundLoop(t *testing.T) {
+	backends := newTestGossipCluster(t, 2, 20*time.Millisecond)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 1, "reset_interval": 3600.0},
+	}
+
+	backends[0].Take(context.Background(), "key", strategy, 1)
+	time.Sleep(150 * time.Millisecond)
+
+	if allowed, _ := backends[1].Take(context.Background(), "key", strategy, 1); allowed {
+		t.Error("Expected counters to be gossiped in the background")
+	}
+}
+
+func TestGossipBackend_Refund(t *testing.T) {
+	backends := newTestGossipCluster(t, 1, time.Hour)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 1, "reset_interval": 3600.0},
+	}
+
+	backends[0].Take(context.Background(), "key", strategy, 1)
+	backends[0].Take(context.Background(), "key", strategy, -1)
+	if allowed, _ := backends[0].Take(context.Background(), "key", strategy, 1); !allowed {
+		t.Error("Expected a refund to return capacity")
+	}
+}
+
+func TestGossipBackend_UnsupportedStrategy(t *testing.T) {
+	backends := newTestGossipCluster(t, 1, time.Hour)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyTokenBucket,
+		Params:       map[string]any{"capacity": 1, "refill_rate": 1, "request_cost": 1},
+	}
+	if _, err := backends[0].Take(context.Background(), "key", strategy, 1); err == nil {
+		t.Error("Expected an error for an unsupported strategy")
+	}
+}
+
+func TestGossipBackend_DefaultInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		closeChan := make(chan struct{})
+		gossip := NewGossipBackend("http://127.0.0.1:1", nil, interval, nil, closeChan)
+		close(closeChan)
+		if gossip == nil {
+			t.Errorf("Expected interval %v to fall back to the default", interval)
+		}
+	}
+}
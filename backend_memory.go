@@ -0,0 +1,50 @@
+package rate_limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBackend keeps one in-process limiter per key. It is not shared
+// between replicas on its own, but serves as the owner-side store of
+// distributed backends and as a stand-in backend in tests.
+//
+// Keys come from clients, so the limiters of each strategy are kept in a
+// keyedRateLimiter, which drops idle keys and bounds how many are tracked.
+type MemoryBackend struct {
+	strategies map[string]*keyedRateLimiter
+	mutex      sync.Mutex
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		strategies: make(map[string]*keyedRateLimiter),
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (m *MemoryBackend) Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error) {
+	limiters, err := m.getLimiters(strategy)
+	if err != nil {
+		return false, err
+	}
+	resp := limiters.evalKey(key, cost)
+	return <-resp.Allowed(), nil
+}
+
+// getLimiters returns the keyed limiters applying strategy. Strategies come
+// from the route configuration, so there are only as many as routes.
+func (m *MemoryBackend) getLimiters(strategy StrategyDescriptor) (*keyedRateLimiter, error) {
+	fingerprint := descriptorFingerprint(strategy)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if limiters, exists := m.strategies[fingerprint]; exists {
+		return limiters, nil
+	}
+	limiters, err := newStrategyKeyedRateLimiter(strategy)
+	if err != nil {
+		return nil, err
+	}
+	m.strategies[fingerprint] = limiters
+	return limiters, nil
+}
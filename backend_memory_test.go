@@ -0,0 +1,57 @@
+package rate_limiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend_KeysAreIndependent(t *testing.T) {
+	backend := NewMemoryBackend()
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 2, "reset_interval": 60.0},
+	}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := backend.Take(context.Background(), "a", strategy, 1); !allowed {
+			t.Errorf("Expected request %d on key a to be allowed", i+1)
+		}
+	}
+	if allowed, _ := backend.Take(context.Background(), "a", strategy, 1); allowed {
+		t.Error("Expected key a to be exhausted")
+	}
+	if allowed, _ := backend.Take(context.Background(), "b", strategy, 2); !allowed {
+		t.Error("Expected key b to have its own bucket")
+	}
+}
+
+func TestMemoryBackend_InvalidStrategy(t *testing.T) {
+	backend := NewMemoryBackend()
+	if _, err := backend.Take(context.Background(), "a", StrategyDescriptor{StrategyName: "unknown"}, 1); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestMemoryBackend_EvictsIdleKeys(t *testing.T) {
+	backend := NewMemoryBackend()
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 2, "reset_interval": 0.05},
+	}
+
+	for i := 0; i < 10; i++ {
+		backend.Take(context.Background(), fmt.Sprintf("client-%d", i), strategy, 1)
+	}
+	limiters, _ := backend.getLimiters(strategy)
+	if limiters.size() != 10 {
+		t.Fatalf("Expected 10 keys, got %d", limiters.size())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	backend.Take(context.Background(), "client-0", strategy, 1)
+	if limiters.size() != 1 {
+		t.Errorf("Expected idle keys to be evicted, got %d keys", limiters.size())
+	}
+}
@@ -0,0 +1,230 @@
+package rate_limiter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SQLPlaceholderStyle int
+
+const (
+	// SQLPlaceholderQuestion binds arguments as ?, as used by SQLite and MySQL.
+	SQLPlaceholderQuestion SQLPlaceholderStyle = iota
+	// SQLPlaceholderDollar binds arguments as $1, $2, ..., as used by PostgreSQL.
+	SQLPlaceholderDollar
+)
+
+const sqlBackendMaxAttempts = 5
+
+var errSQLBackendContention = errors.New("sql backend: too much contention on bucket")
+
+// sqlMigrations are applied in order by MigrateSQLBackend. Never edit an
+// entry that has been released; append a new one instead.
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS rate_limiter_fixed_windows (
+		bucket_key VARCHAR(255) NOT NULL,
+		window_start BIGINT NOT NULL,
+		request_count DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (bucket_key, window_start)
+	)`,
+	`CREATE TABLE IF NOT EXISTS rate_limiter_token_buckets (
+		bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		last_refill BIGINT NOT NULL
+	)`,
+}
+
+// MigrateSQLBackend creates or upgrades the tables used by SQLBackend. It is
+// safe to call on every start; applied versions are recorded in
+// rate_limiter_schema_migrations.
+func MigrateSQLBackend(ctx context.Context, db *sql.DB, placeholder SQLPlaceholderStyle) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS rate_limiter_schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	row := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM rate_limiter_schema_migrations`)
+	if err := row.Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for version := current + 1; version <= len(sqlMigrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqlMigrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+		insert := bindSQLPlaceholders(`INSERT INTO rate_limiter_schema_migrations (version) VALUES (?)`, placeholder)
+		if _, err := tx.ExecContext(ctx, insert, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLBackend stores limiter state in a relational database through
+// database/sql. Every decision is a single conditional statement on one row,
+// so concurrent replicas never overshoot a bucket. Fixed windows are aligned
+// to the Unix epoch and kept as one row per window, which leaves an audit
+// trail of past usage.
+type SQLBackend struct {
+	db          *sql.DB
+	placeholder SQLPlaceholderStyle
+}
+
+func NewSQLBackend(db *sql.DB, placeholder SQLPlaceholderStyle) *SQLBackend {
+	return &SQLBackend{
+		db:          db,
+		placeholder: placeholder,
+	}
+}
+
+func (s *SQLBackend) Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error) {
+	switch strategy.StrategyName {
+	case LimiterStrategyFixedWindow:
+		params, err := GetFixedWindowRateLimiterParamsFromMap(strategy.Params)
+		if err != nil {
+			return false, err
+		}
+		return s.takeFixedWindow(ctx, key, params, cost, time.Now())
+	case LimiterStrategyTokenBucket:
+		params, err := getTokenBucketRateLimiterParamsFromMap(strategy.Params)
+		if err != nil {
+			return false, err
+		}
+		return s.takeTokenBucket(ctx, key, params, cost, time.Now())
+	default:
+		return false, fmt.Errorf("sql backend does not support strategy: %s", strategy.StrategyName)
+	}
+}
+
+func (s *SQLBackend) takeFixedWindow(ctx context.Context, key string, params FixedWindowRateLimiterParams, cost float64, now time.Time) (bool, error) {
+	if params.ResetInterval <= 0 {
+		return false, errors.New("invalid reset_interval parameter")
+	}
+	windowStart := now.UnixNano() - now.UnixNano()%int64(params.ResetInterval)
+	capacity := float64(params.Capacity)
+
+	update := s.bind(`UPDATE rate_limiter_fixed_windows SET request_count = request_count + ?
+		WHERE bucket_key = ? AND window_start = ? AND request_count + ? <= ?`)
+	selectCount := s.bind(`SELECT request_count FROM rate_limiter_fixed_windows WHERE bucket_key = ? AND window_start = ?`)
+	insert := s.bind(`INSERT INTO rate_limiter_fixed_windows (bucket_key, window_start, request_count) VALUES (?, ?, ?)`)
+
+	for attempt := 0; attempt < sqlBackendMaxAttempts; attempt++ {
+		result, err := s.db.ExecContext(ctx, update, cost, key, windowStart, cost, capacity)
+		if err != nil {
+			return false, err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return false, err
+		} else if affected == 1 {
+			return true, nil
+		}
+
+		var count float64
+		err = s.db.QueryRowContext(ctx, selectCount, key, windowStart).Scan(&count)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		}
+
+		if cost > capacity {
+			return false, nil
+		}
+		// Another replica may open the window first, in which case the insert
+		// fails on the primary key and the update is retried.
+		if _, err := s.db.ExecContext(ctx, insert, key, windowStart, cost); err == nil {
+			return true, nil
+		} else if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+	}
+	return false, errSQLBackendContention
+}
+
+func (s *SQLBackend) takeTokenBucket(ctx context.Context, key string, params tokenBucketRateLimiterParams, cost float64, now time.Time) (bool, error) {
+	requestCost := params.RequestCost * cost
+
+	selectBucket := s.bind(`SELECT tokens, last_refill FROM rate_limiter_token_buckets WHERE bucket_key = ?`)
+	update := s.bind(`UPDATE rate_limiter_token_buckets SET tokens = ?, last_refill = ?
+		WHERE bucket_key = ? AND tokens = ? AND last_refill = ?`)
+	insert := s.bind(`INSERT INTO rate_limiter_token_buckets (bucket_key, tokens, last_refill) VALUES (?, ?, ?)`)
+
+	for attempt := 0; attempt < sqlBackendMaxAttempts; attempt++ {
+		var tokens float64
+		var lastRefill int64
+		err := s.db.QueryRowContext(ctx, selectBucket, key).Scan(&tokens, &lastRefill)
+		if errors.Is(err, sql.ErrNoRows) {
+			if requestCost > params.Capacity {
+				return false, nil
+			}
+			if _, err := s.db.ExecContext(ctx, insert, key, params.Capacity-requestCost, now.UnixNano()); err == nil {
+				return true, nil
+			} else if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		elapsed := time.Duration(max(now.UnixNano()-lastRefill, 0))
+		available := min(params.Capacity, tokens+elapsed.Seconds()*params.RefillRate)
+		allowed := available >= requestCost
+		if allowed {
+			available -= requestCost
+		}
+
+		// The update only applies if no other replica touched the row since
+		// it was read; otherwise the decision is recomputed.
+		result, err := s.db.ExecContext(ctx, update, available, max(now.UnixNano(), lastRefill), key, tokens, lastRefill)
+		if err != nil {
+			return false, err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return false, err
+		} else if affected == 1 {
+			return allowed, nil
+		}
+	}
+	return false, errSQLBackendContention
+}
+
+func (s *SQLBackend) bind(query string) string {
+	return bindSQLPlaceholders(query, s.placeholder)
+}
+
+func bindSQLPlaceholders(query string, placeholder SQLPlaceholderStyle) string {
+	if placeholder != SQLPlaceholderDollar {
+		return query
+	}
+	var builder strings.Builder
+	index := 0
+	for _, char := range query {
+		if char == '?' {
+			index++
+			builder.WriteString("$" + strconv.Itoa(index))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
@@ -0,0 +1,150 @@
+package rate_limiter
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteTestBackend(t *testing.T) (*SQLBackend, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := MigrateSQLBackend(context.Background(), db, SQLPlaceholderQuestion); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewSQLBackend(db, SQLPlaceholderQuestion), db
+}
+
+func TestMigrateSQLBackend_Idempotent(t *testing.T) {
+	_, db := newSQLiteTestBackend(t)
+
+	if err := MigrateSQLBackend(context.Background(), db, SQLPlaceholderQuestion); err != nil {
+		t.Fatalf("Expected second migration to be a no-op, got %v", err)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM rate_limiter_schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(sqlMigrations) {
+		t.Errorf("Expected schema version %d, got %d", len(sqlMigrations), version)
+	}
+}
+
+func TestSQLBackend_FixedWindow(t *testing.T) {
+	backend, db := newSQLiteTestBackend(t)
+	params := FixedWindowRateLimiterParams{Capacity: 3, ResetInterval: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := backend.takeFixedWindow(context.Background(), "tier:gold", params, 1, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !allowed {
+			t.Errorf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	allowed, err := backend.takeFixedWindow(context.Background(), "tier:gold", params, 1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed {
+		t.Error("Expected request over quota to be blocked")
+	}
+
+	// The next window starts a new audited row
+	allowed, _ = backend.takeFixedWindow(context.Background(), "tier:gold", params, 1, now.Add(time.Hour))
+	if !allowed {
+		t.Error("Expected request in the next window to be allowed")
+	}
+
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM rate_limiter_fixed_windows WHERE bucket_key = ?`, "tier:gold").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("Expected one row per window, got %d", rows)
+	}
+}
+
+func TestSQLBackend_TokenBucket(t *testing.T) {
+	backend, _ := newSQLiteTestBackend(t)
+	params := tokenBucketRateLimiterParams{Capacity: 2, RefillRate: 1, RequestCost: 1}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := backend.takeTokenBucket(context.Background(), "client", params, 1, now); err != nil || !allowed {
+			t.Fatalf("Expected request %d to be allowed, got %v (%v)", i+1, allowed, err)
+		}
+	}
+
+	if allowed, _ := backend.takeTokenBucket(context.Background(), "client", params, 1, now); allowed {
+		t.Error("Expected empty bucket to block")
+	}
+
+	if allowed, _ := backend.takeTokenBucket(context.Background(), "client", params, 1, now.Add(1100*time.Millisecond)); !allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+}
+
+func TestSQLBackend_ConcurrentReplicas(t *testing.T) {
+	backend, _ := newSQLiteTestBackend(t)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 20, "reset_interval": 3600.0},
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowedCount := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed, err := backend.Take(context.Background(), "shared", strategy, 1)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if allowed {
+				mu.Lock()
+				allowedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowedCount != 20 {
+		t.Errorf("Expected 20 allowed requests, got %d", allowedCount)
+	}
+}
+
+func TestSQLBackend_UnsupportedStrategy(t *testing.T) {
+	backend, _ := newSQLiteTestBackend(t)
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategySlidingWindowLog,
+		Params:       map[string]any{"capacity": 1, "window_size": 1.0},
+	}
+	if _, err := backend.Take(context.Background(), "key", strategy, 1); err == nil {
+		t.Error("Expected an error for an unsupported strategy")
+	}
+}
+
+func TestBindSQLPlaceholders(t *testing.T) {
+	query := bindSQLPlaceholders("SELECT a FROM t WHERE b = ? AND c = ?", SQLPlaceholderDollar)
+	if query != "SELECT a FROM t WHERE b = $1 AND c = $2" {
+		t.Errorf("Unexpected query: %s", query)
+	}
+}
@@ -0,0 +1,224 @@
+package rate_limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is an amount of bytes. In configuration files it is either a
+// number or a string with a unit, such as "512KB" or "1MiB"; a trailing "/s"
+// is accepted for rates.
+type ByteSize float64
+
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+func ParseByteSize(value string) (ByteSize, error) {
+	trimmed := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "/s")
+	index := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if index < 0 {
+		index = len(trimmed)
+	}
+	number, err := strconv.ParseFloat(trimmed[:index], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size: %s", value)
+	}
+	unit, exists := byteSizeUnits[strings.TrimSpace(trimmed[index:])]
+	if !exists {
+		return 0, fmt.Errorf("invalid byte size unit: %s", value)
+	}
+	return ByteSize(number * unit), nil
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		*b = ByteSize(number)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid byte size: %s", data)
+	}
+	size, err := ParseByteSize(text)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	size, err := ParseByteSize(value.Value)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// BandwidthDescriptor limits the bytes per second of request and response
+// bodies. Burst is the amount that can be transferred at once after a pause,
+// and defaults to Rate.
+type BandwidthDescriptor struct {
+	Rate  ByteSize `json:"rate" yaml:"rate"`
+	Burst ByteSize `json:"burst,omitempty" yaml:"burst,omitempty"`
+}
+
+var errBandwidthExceedsBurst = errors.New("bandwidth: amount exceeds burst")
+
+// BandwidthLimiter is a token bucket in bytes. Transfers over the limit wait
+// for the bucket to refill instead of failing. It is safe for concurrent use.
+type BandwidthLimiter struct {
+	bucket *tokenBucketRateLimiter
+	burst  int
+}
+
+var errInvalidBandwidth = errors.New("invalid bandwidth parameters")
+
+// NewBandwidthLimiter creates a limiter of rate bytes per second with bursts
+// of burst bytes. It fails unless rate is positive and burst is at least one
+// byte, as readers and writers would otherwise never make progress.
+func NewBandwidthLimiter(rate, burst ByteSize) (*BandwidthLimiter, error) {
+	if !(rate > 0) || !(burst >= 1) || burst > math.MaxInt32 {
+		return nil, errInvalidBandwidth
+	}
+	return newBandwidthLimiter(rate, burst), nil
+}
+
+// newBandwidthLimiter creates a limiter of parameters already validated.
+func newBandwidthLimiter(rate, burst ByteSize) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		bucket: newTokenBucketRateLimiter(float64(burst), float64(rate), 1),
+		burst:  int(burst),
+	}
+}
+
+// WaitN blocks until n bytes can be transferred, or returns the context
+// error if ctx is done first. n must not exceed the burst.
+func (b *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	if n > b.burst {
+		return errBandwidthExceedsBurst
+	}
+	if n <= 0 {
+		return nil
+	}
+	return waitAllowed(ctx, func() (RequestPipelineResponse, bool) {
+		return b.bucket.evalCost(float64(n)), true
+	})
+}
+
+// Burst returns the largest amount WaitN accepts at once.
+func (b *BandwidthLimiter) Burst() int {
+	return b.burst
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *BandwidthLimiter
+}
+
+// NewLimitedReader paces reads from r to the limiter rate. Reads wait until
+// ctx is done at most.
+func NewLimitedReader(ctx context.Context, r io.Reader, limiter *BandwidthLimiter) io.Reader {
+	return &limitedReader{ctx: ctx, reader: r, limiter: limiter}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > l.limiter.burst {
+		p = p[:l.limiter.burst]
+	}
+	n, err := l.reader.Read(p)
+	if waitErr := l.limiter.WaitN(l.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+type limitedWriter struct {
+	ctx     context.Context
+	writer  io.Writer
+	limiter *BandwidthLimiter
+}
+
+// NewLimitedWriter paces writes to w to the limiter rate, splitting writes
+// larger than the burst. Writes wait until ctx is done at most.
+func NewLimitedWriter(ctx context.Context, w io.Writer, limiter *BandwidthLimiter) io.Writer {
+	return &limitedWriter{ctx: ctx, writer: w, limiter: limiter}
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), l.limiter.burst)]
+		if err := l.limiter.WaitN(l.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := l.writer.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// keyedBandwidthLimiter keeps an upload and a download limiter per client
+// key of a route. Like keyedRateLimiter, it drops a key once it has been idle
+// long enough for both buckets to be full again, and bounds how many keys it
+// tracks.
+type keyedBandwidthLimiter struct {
+	clients *keyedStore[clientBandwidth]
+}
+
+type clientBandwidth struct {
+	upload   *BandwidthLimiter
+	download *BandwidthLimiter
+}
+
+func newKeyedBandwidthLimiter(descriptor BandwidthDescriptor) (*keyedBandwidthLimiter, error) {
+	rate, burst := descriptor.Rate, descriptor.Burst
+	if burst == 0 {
+		burst = rate
+	}
+	if _, err := NewBandwidthLimiter(rate, burst); err != nil {
+		return nil, err
+	}
+	return &keyedBandwidthLimiter{
+		clients: newKeyedStore(func(string) clientBandwidth {
+			return clientBandwidth{
+				upload:   newBandwidthLimiter(rate, burst),
+				download: newBandwidthLimiter(rate, burst),
+			}
+		}, time.Duration(float64(burst)/float64(rate)*float64(time.Second))),
+	}, nil
+}
+
+func (k *keyedBandwidthLimiter) limiters(key string) (*BandwidthLimiter, *BandwidthLimiter) {
+	client := k.clients.get(key)
+	return client.upload, client.download
+}
@@ -0,0 +1,197 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected ByteSize
+	}{
+		{"512", 512},
+		{"1.5KB", 1500},
+		{"2k", 2000},
+		{"1MiB", 1 << 20},
+		{"4 MiB", 4 << 20},
+		{"1gb/s", 1e9},
+		{"3GiB", 3 << 30},
+	}
+	for _, tt := range tests {
+		size, err := ParseByteSize(tt.value)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.value, err)
+			continue
+		}
+		if size != tt.expected {
+			t.Errorf("Expected %q to be %v, got %v", tt.value, tt.expected, size)
+		}
+	}
+
+	for _, value := range []string{"", "MiB", "1TB", "fast"} {
+		if _, err := ParseByteSize(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestBandwidthDescriptor_Load(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	err := builder.LoadFromYaml([]byte(`
+- path: /files/*
+  bandwidth: {rate: 1MiB, burst: 4MiB}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.LoadFromJson([]byte(`[{"path": "/uploads", "bandwidth": {"rate": 1024}}]`)); err != nil {
+		t.Fatal(err)
+	}
+	router := mustBuildRouter(t, &builder)
+
+	upload, download, matched := router.BandwidthLimiters(RouteRequest{Method: http.MethodGet, Path: "/files/a.zip"}, "10.0.0.1")
+	if !matched {
+		t.Fatal("Expected the route to limit bandwidth")
+	}
+	if upload == download {
+		t.Error("Expected separate upload and download limiters")
+	}
+	if download.Burst() != 4<<20 {
+		t.Errorf("Expected a 4MiB burst, got %d", download.Burst())
+	}
+	if _, other, _ := router.BandwidthLimiters(RouteRequest{Method: http.MethodGet, Path: "/files/a.zip"}, "10.0.0.2"); other == download {
+		t.Error("Expected another key to have its own limiters")
+	}
+
+	if _, download, _ := router.BandwidthLimiters(RouteRequest{Method: http.MethodGet, Path: "/uploads"}, ""); download.Burst() != 1024 {
+		t.Errorf("Expected the burst to default to the rate, got %d", download.Burst())
+	}
+	if _, _, matched := router.BandwidthLimiters(RouteRequest{Method: http.MethodGet, Path: "/other"}, ""); matched {
+		t.Error("Expected no bandwidth limit on unmatched paths")
+	}
+}
+
+func TestLimitedWriter_PacesWrites(t *testing.T) {
+	limiter := newTestBandwidthLimiter(t, 10000, 1000)
+	var output bytes.Buffer
+	writer := NewLimitedWriter(context.Background(), &output, limiter)
+
+	start := time.Now()
+	n, err := writer.Write(make([]byte, 3000))
+	if err != nil || n != 3000 {
+		t.Fatalf("Expected 3000 bytes written, got %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 2000 bytes over the burst to take 200ms, took %v", elapsed)
+	}
+	if output.Len() != 3000 {
+		t.Errorf("Expected every byte to reach the writer, got %d", output.Len())
+	}
+}
+
+func TestLimitedReader_PacesReads(t *testing.T) {
+	limiter := newTestBandwidthLimiter(t, 10000, 1000)
+	reader := NewLimitedReader(context.Background(), bytes.NewReader(make([]byte, 3000)), limiter)
+
+	start := time.Now()
+	data, err := io.ReadAll(reader)
+	if err != nil || len(data) != 3000 {
+		t.Fatalf("Expected 3000 bytes read, got %d, %v", len(data), err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 2000 bytes over the burst to take 200ms, took %v", elapsed)
+	}
+}
+
+func TestBandwidthLimiter_WaitN(t *testing.T) {
+	limiter := newTestBandwidthLimiter(t, 100, 100)
+
+	if err := limiter.WaitN(context.Background(), 101); !errors.Is(err, errBandwidthExceedsBurst) {
+		t.Errorf("Expected an error over the burst, got %v", err)
+	}
+	if err := limiter.WaitN(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.WaitN(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
+
+func newTestBandwidthLimiter(t *testing.T, rate, burst ByteSize) *BandwidthLimiter {
+	t.Helper()
+	limiter, err := NewBandwidthLimiter(rate, burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter
+}
+
+func TestNewBandwidthLimiter_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  ByteSize
+		burst ByteSize
+	}{
+		{"zero rate", 0, 100},
+		{"negative rate", -1, 100},
+		{"NaN rate", ByteSize(math.NaN()), 100},
+		{"zero burst", 100, 0},
+		{"burst under a byte", 100, 0.5},
+		{"negative burst", 100, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBandwidthLimiter(tt.rate, tt.burst); err == nil {
+				t.Error("Expected invalid parameters to be rejected")
+			}
+		})
+	}
+}
+
+func TestBandwidthDescriptor_InvalidParameters(t *testing.T) {
+	for _, descriptor := range []BandwidthDescriptor{{Rate: 0}, {Rate: 100, Burst: 0.5}, {Rate: -100, Burst: 100}} {
+		if _, err := newKeyedBandwidthLimiter(descriptor); err == nil {
+			t.Errorf("Expected %+v to be rejected", descriptor)
+		}
+	}
+}
+
+func TestKeyedBandwidthLimiter_EvictsIdleKeys(t *testing.T) {
+	// A 50 byte burst refills in 50ms at 1000 bytes per second
+	keyed, err := newKeyedBandwidthLimiter(BandwidthDescriptor{Rate: 1000, Burst: 50})
+	if err != nil {
+		t.Fatalf("Expected descriptor to be valid, got %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		keyed.limiters(fmt.Sprintf("client-%d", i))
+	}
+	if keyed.clients.size() != 10 {
+		t.Fatalf("Expected 10 keys, got %d", keyed.clients.size())
+	}
+
+	upload, _ := keyed.limiters("client-0")
+	if again, _ := keyed.limiters("client-0"); again != upload {
+		t.Error("Expected a key in use to keep its limiters")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	keyed.limiters("client-0")
+	if keyed.clients.size() != 1 {
+		t.Errorf("Expected idle keys to be evicted, got %d keys", keyed.clients.size())
+	}
+}
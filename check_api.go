@@ -0,0 +1,103 @@
+package rate_limiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// CheckPath is the endpoint of the decision API served by NewCheckHandler.
+const CheckPath = "/v1/check"
+
+// CheckRequest asks for a decision on a request. Method is optional; without
+// it only routes for any method match. Headers and Query are only needed by
+// routes with match conditions.
+type CheckRequest struct {
+	Host    string      `json:"host,omitempty"`
+	Method  string      `json:"method,omitempty"`
+	Path    string      `json:"path"`
+	Headers http.Header `json:"headers,omitempty"`
+	Query   url.Values  `json:"query,omitempty"`
+	Key     string      `json:"key,omitempty"`
+	Cost    float64     `json:"cost,omitempty"`
+}
+
+// CheckResponse is the full decision for a CheckRequest. Durations are in
+// seconds, like the configuration.
+type CheckResponse struct {
+	Allowed    bool    `json:"allowed"`
+	Matched    bool    `json:"matched"`
+	Limit      float64 `json:"limit"`
+	Remaining  float64 `json:"remaining"`
+	ResetAfter float64 `json:"reset_after"`
+	RetryAfter float64 `json:"retry_after"`
+	// Pattern and Params describe the matched route, see RouteMatch.
+	Pattern string            `json:"pattern,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+	// Fallback is set by CheckClient when the decision was taken by its
+	// failure policy instead of the server.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+type checkErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// newCheckResponse waits for the decision, including any traffic shaping
+// delay.
+func newCheckResponse(resp RequestPipelineResponse, matched bool) CheckResponse {
+	return CheckResponse{
+		Allowed:    <-resp.Allowed(),
+		Matched:    matched,
+		Limit:      resp.Limit(),
+		Remaining:  resp.Remaining(),
+		ResetAfter: resp.ResetAfter().Seconds(),
+		RetryAfter: resp.RetryAfter().Seconds(),
+		Pattern:    resp.Pattern(),
+		Params:     resp.Params(),
+	}
+}
+
+// NewCheckHandler serves decisions of router over HTTP so services written in
+// other languages can share the same limits. Mount it at CheckPath.
+func NewCheckHandler(router Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(checkErrorResponse{Error: "method not allowed"})
+			return
+		}
+
+		var checkRequest CheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&checkRequest); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(checkErrorResponse{Error: err.Error()})
+			return
+		}
+		if checkRequest.Path == "" || checkRequest.Cost < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(checkErrorResponse{Error: "path is required and cost must not be negative"})
+			return
+		}
+		if checkRequest.Cost == 0 {
+			checkRequest.Cost = 1
+		}
+
+		// Header names in JSON are not canonical
+		header := make(http.Header, len(checkRequest.Headers))
+		for name, values := range checkRequest.Headers {
+			for _, value := range values {
+				header.Add(name, value)
+			}
+		}
+		resp, matched := router.HandleRouteRequest(RouteRequest{
+			Host:   checkRequest.Host,
+			Method: checkRequest.Method,
+			Path:   checkRequest.Path,
+			Header: header,
+			Query:  checkRequest.Query,
+		}, checkRequest.Key, checkRequest.Cost)
+		json.NewEncoder(w).Encode(newCheckResponse(resp, matched))
+	})
+}
@@ -0,0 +1,92 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestCheckRouter(closeChan <-chan struct{}) Router {
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(RouteDescriptor{
+		Path: "/api/:id",
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyFixedWindow,
+			Params:       map[string]any{"capacity": 3, "reset_interval": 60.0},
+		},
+	})
+	router, _ := builder.Build()
+	return router
+}
+
+func postCheck(t *testing.T, handler http.Handler, body string) (int, CheckResponse) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, CheckPath, bytes.NewBufferString(body)))
+
+	var checkResponse CheckResponse
+	json.NewDecoder(recorder.Body).Decode(&checkResponse)
+	return recorder.Code, checkResponse
+}
+
+func TestCheckHandler_FullDecision(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := NewCheckHandler(newTestCheckRouter(closeChan))
+
+	status, decision := postCheck(t, handler, `{"path": "/api/1", "key": "client-a", "cost": 2}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if !decision.Allowed || !decision.Matched || decision.Limit != 3 || decision.Remaining != 1 {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+	if decision.Pattern != "/api/:id" || decision.Params["id"] != "1" {
+		t.Errorf("Expected the matched route, got pattern %q and params %v", decision.Pattern, decision.Params)
+	}
+
+	_, decision = postCheck(t, handler, `{"path": "/api/1", "key": "client-a", "cost": 2}`)
+	if decision.Allowed {
+		t.Error("Expected request over the key's capacity to be blocked")
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > 60 {
+		t.Errorf("Expected retry_after within the window, got %v", decision.RetryAfter)
+	}
+
+	// Other keys have their own bucket
+	_, decision = postCheck(t, handler, `{"path": "/api/1", "key": "client-b"}`)
+	if !decision.Allowed || decision.Remaining != 2 {
+		t.Errorf("Expected a fresh bucket for client-b, got %+v", decision)
+	}
+}
+
+func TestCheckHandler_UnmatchedPath(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := NewCheckHandler(newTestCheckRouter(closeChan))
+
+	_, decision := postCheck(t, handler, `{"path": "/other"}`)
+	if !decision.Allowed || decision.Matched {
+		t.Errorf("Expected unmatched path to be allowed, got %+v", decision)
+	}
+}
+
+func TestCheckHandler_BadRequest(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := NewCheckHandler(newTestCheckRouter(closeChan))
+
+	for _, body := range []string{`not json`, `{"key": "a"}`, `{"path": "/api/1", "cost": -1}`} {
+		if status, _ := postCheck(t, handler, body); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, status)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, CheckPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCheckClientTimeout      = 200 * time.Millisecond
+	defaultCheckClientMaxIdleConns = 64
+)
+
+type CheckClientOptions struct {
+	// Timeout bounds each call, including connection setup. Defaults to 200ms.
+	Timeout time.Duration
+	// MaxIdleConns is the number of pooled keep-alive connections to the
+	// server. Defaults to 64.
+	MaxIdleConns int
+	// FailurePolicy decides what Check returns when the server cannot be
+	// reached. When empty, the error is returned instead.
+	FailurePolicy FailurePolicy
+	// Fallback answers requests under FailurePolicyLocal, usually a router
+	// built from the same configuration as the server.
+	Fallback *Router
+}
+
+// CheckClient calls the decision API served by NewCheckHandler. It is safe
+// for concurrent use and reuses connections between calls.
+type CheckClient struct {
+	baseURL       string
+	client        *http.Client
+	failurePolicy FailurePolicy
+	fallback      *Router
+}
+
+func NewCheckClient(baseURL string, options CheckClientOptions) (*CheckClient, error) {
+	switch options.FailurePolicy {
+	case "", FailurePolicyOpen, FailurePolicyClosed:
+	case FailurePolicyLocal:
+		if options.Fallback == nil {
+			return nil, fmt.Errorf("failure policy %s requires a fallback router", FailurePolicyLocal)
+		}
+	default:
+		return nil, fmt.Errorf("unknown failure policy: %s", options.FailurePolicy)
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultCheckClientTimeout
+	}
+	maxIdleConns := options.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultCheckClientMaxIdleConns
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+
+	return &CheckClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		failurePolicy: options.FailurePolicy,
+		fallback:      options.Fallback,
+	}, nil
+}
+
+// Check asks for a decision on a request. An empty method only matches routes
+// for any method.
+func (c *CheckClient) Check(ctx context.Context, method, path, key string, cost float64) (CheckResponse, error) {
+	return c.CheckRoute(ctx, RouteRequest{Method: method, Path: path}, key, cost)
+}
+
+// CheckRoute is Check for a request that may also be matched by host,
+// headers and query parameters.
+func (c *CheckClient) CheckRoute(ctx context.Context, request RouteRequest, key string, cost float64) (CheckResponse, error) {
+	checkResponse, err := c.check(ctx, CheckRequest{
+		Host:    request.Host,
+		Method:  request.Method,
+		Path:    request.Path,
+		Headers: request.Header,
+		Query:   request.Query,
+		Key:     key,
+		Cost:    cost,
+	})
+	if err == nil {
+		return checkResponse, nil
+	}
+	// Rejected requests are the caller's mistake, not an outage.
+	var statusErr checkStatusError
+	if errors.As(err, &statusErr) && statusErr.status < http.StatusInternalServerError {
+		return CheckResponse{}, err
+	}
+
+	switch c.failurePolicy {
+	case FailurePolicyOpen:
+		return CheckResponse{Allowed: true, Fallback: true}, nil
+	case FailurePolicyClosed:
+		return CheckResponse{Allowed: false, Fallback: true}, nil
+	case FailurePolicyLocal:
+		if cost == 0 {
+			cost = 1
+		}
+		resp, matched := c.fallback.HandleRouteRequest(request, key, cost)
+		checkResponse := newCheckResponse(resp, matched)
+		checkResponse.Fallback = true
+		return checkResponse, nil
+	default:
+		return CheckResponse{}, err
+	}
+}
+
+func (c *CheckClient) check(ctx context.Context, checkRequest CheckRequest) (CheckResponse, error) {
+	body, err := json.Marshal(checkRequest)
+	if err != nil {
+		return CheckResponse{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+CheckPath, bytes.NewReader(body))
+	if err != nil {
+		return CheckResponse{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return CheckResponse{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var errorResponse checkErrorResponse
+		json.NewDecoder(response.Body).Decode(&errorResponse)
+		return CheckResponse{}, checkStatusError{status: response.StatusCode, message: errorResponse.Error}
+	}
+
+	var checkResponse CheckResponse
+	if err := json.NewDecoder(response.Body).Decode(&checkResponse); err != nil {
+		return CheckResponse{}, fmt.Errorf("invalid check response: %w", err)
+	}
+	return checkResponse, nil
+}
+
+type checkStatusError struct {
+	status  int
+	message string
+}
+
+func (c checkStatusError) Error() string {
+	return fmt.Sprintf("check failed with status %d: %s", c.status, c.message)
+}
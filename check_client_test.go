@@ -0,0 +1,108 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckClient_Check(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	server := httptest.NewServer(NewCheckHandler(newTestCheckRouter(closeChan)))
+	defer server.Close()
+
+	client, err := NewCheckClient(server.URL, CheckClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		decision, err := client.Check(context.Background(), http.MethodGet, "/api/1", "client", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decision.Allowed || decision.Fallback {
+			t.Errorf("Expected request %d to be allowed by the server, got %+v", i+1, decision)
+		}
+	}
+	decision, _ := client.Check(context.Background(), http.MethodGet, "/api/1", "client", 1)
+	if decision.Allowed {
+		t.Error("Expected fourth request to be blocked")
+	}
+}
+
+func TestCheckClient_FailurePolicies(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	// Nothing listens on this address
+	unreachable := "http://127.0.0.1:1"
+	fallback := newTestCheckRouter(closeChan)
+
+	tests := []struct {
+		policy   FailurePolicy
+		expected bool
+	}{
+		{FailurePolicyOpen, true},
+		{FailurePolicyClosed, false},
+		{FailurePolicyLocal, true},
+	}
+	for _, test := range tests {
+		client, err := NewCheckClient(unreachable, CheckClientOptions{FailurePolicy: test.policy, Fallback: &fallback})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decision, err := client.Check(context.Background(), http.MethodGet, "/api/1", "client", 1)
+		if err != nil {
+			t.Fatalf("Expected %s to hide the error, got %v", test.policy, err)
+		}
+		if decision.Allowed != test.expected || !decision.Fallback {
+			t.Errorf("Expected %s fallback decision allowed=%v, got %+v", test.policy, test.expected, decision)
+		}
+	}
+
+	client, _ := NewCheckClient(unreachable, CheckClientOptions{})
+	if _, err := client.Check(context.Background(), http.MethodGet, "/api/1", "client", 1); err == nil {
+		t.Error("Expected an error without a failure policy")
+	}
+}
+
+func TestCheckClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, _ := NewCheckClient(server.URL, CheckClientOptions{Timeout: 50 * time.Millisecond, FailurePolicy: FailurePolicyClosed})
+	start := time.Now()
+	decision, _ := client.Check(context.Background(), http.MethodGet, "/api/1", "client", 1)
+	if decision.Allowed || !decision.Fallback {
+		t.Errorf("Expected timed out call to fail closed, got %+v", decision)
+	}
+	if time.Since(start) > 300*time.Millisecond {
+		t.Errorf("Expected the timeout to cut the call short, took %v", time.Since(start))
+	}
+}
+
+func TestCheckClient_BadRequestIsNotAnOutage(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	server := httptest.NewServer(NewCheckHandler(newTestCheckRouter(closeChan)))
+	defer server.Close()
+
+	client, _ := NewCheckClient(server.URL, CheckClientOptions{FailurePolicy: FailurePolicyOpen})
+	if _, err := client.Check(context.Background(), http.MethodGet, "", "client", 1); err == nil {
+		t.Error("Expected a bad request to be reported instead of failing open")
+	}
+}
+
+func TestNewCheckClient_LocalPolicyRequiresFallback(t *testing.T) {
+	if _, err := NewCheckClient("http://localhost", CheckClientOptions{FailurePolicy: FailurePolicyLocal}); err == nil {
+		t.Error("Expected an error without a fallback router")
+	}
+}
@@ -0,0 +1,72 @@
+package rate_limiter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker opens after failureThreshold consecutive failures and stays
+// open for openInterval. After that a single probe is let through; its result
+// decides whether the circuit closes again or reopens.
+type circuitBreaker struct {
+	state            circuitState
+	failures         int
+	failureThreshold int
+	openedAt         time.Time
+	openInterval     time.Duration
+	mutex            sync.Mutex
+}
+
+func newCircuitBreaker(failureThreshold int, openInterval time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		state:            circuitClosed,
+		failureThreshold: failureThreshold,
+		openInterval:     openInterval,
+		mutex:            sync.Mutex{},
+	}
+}
+
+func (c *circuitBreaker) allow() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch c.state {
+	case circuitOpen:
+		if time.Since(c.openedAt) < c.openInterval {
+			return false
+		}
+		c.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *circuitBreaker) success() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failures = 0
+	c.state = circuitClosed
+}
+
+func (c *circuitBreaker) failure() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= c.failureThreshold {
+		c.state = circuitOpen
+		c.openedAt = time.Now()
+	}
+}
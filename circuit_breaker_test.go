@@ -0,0 +1,49 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	breaker := newCircuitBreaker(2, 100*time.Millisecond)
+
+	breaker.failure()
+	if !breaker.allow() {
+		t.Fatal("Expected breaker to stay closed below the threshold")
+	}
+
+	breaker.failure()
+	if breaker.allow() {
+		t.Fatal("Expected breaker to open after reaching the threshold")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	interval := 100 * time.Millisecond
+	breaker := newCircuitBreaker(1, interval)
+
+	breaker.failure()
+	time.Sleep(interval + 20*time.Millisecond)
+
+	// Only one probe passes while half open
+	if !breaker.allow() {
+		t.Fatal("Expected a probe to be allowed after the open interval")
+	}
+	if breaker.allow() {
+		t.Fatal("Expected concurrent probes to be rejected while half open")
+	}
+
+	// A failed probe reopens the circuit
+	breaker.failure()
+	if breaker.allow() {
+		t.Fatal("Expected breaker to reopen after a failed probe")
+	}
+
+	time.Sleep(interval + 20*time.Millisecond)
+	breaker.allow()
+	breaker.success()
+	if !breaker.allow() {
+		t.Fatal("Expected breaker to close after a successful probe")
+	}
+}
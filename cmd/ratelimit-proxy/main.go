@@ -0,0 +1,170 @@
+// Command ratelimit-proxy is a reverse proxy sidecar that enforces the route
+// limits of a JSON or YAML file in front of a service that cannot embed the
+// library. The file is reloaded on SIGHUP and whenever it changes.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+func main() {
+	upstream := flag.String("upstream", "", "URL of the protected service")
+	configPath := flag.String("config", "routes.yaml", "route configuration file (.json, .yaml or .yml)")
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	key := flag.String("key", "ip", "client key: ip, header:<Name>, or none for route-wide limits")
+	reloadInterval := flag.Duration("reload-interval", 5*time.Second, "how often to check the config file for changes (0 disables)")
+	healthPrefix := flag.String("health-prefix", "/_ratelimit", "path prefix of the proxy's own healthz and readyz endpoints")
+	normalize := flag.String("normalize", "", "comma-separated path normalization options, replacing those of the config file: clean_dot_segments, collapse_slashes, case_insensitive, decode_percent, strip_query")
+	flag.Parse()
+
+	normalization, err := rate_limiter.ParsePathNormalization(*normalize)
+	if err != nil {
+		log.Fatalf("invalid -normalize: %v", err)
+	}
+
+	upstreamURL, err := url.Parse(*upstream)
+	if err != nil || upstreamURL.Scheme == "" || upstreamURL.Host == "" {
+		log.Fatalf("invalid -upstream %q", *upstream)
+	}
+	keyFunc, err := parseKeyFunc(*key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router, err := rate_limiter.NewReloadableRouter(func(closeSign <-chan struct{}) (rate_limiter.Router, error) {
+		builder := rate_limiter.NewRouterBuilder(closeSign)
+		if err := builder.LoadFromFile(*configPath); err != nil {
+			return rate_limiter.Router{}, err
+		}
+		if *normalize != "" {
+			builder.SetPathNormalization(normalization)
+		}
+		logRouteWarnings(builder.Analyze())
+		return builder.Build()
+	})
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	defer router.Close()
+
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	mux := http.NewServeMux()
+	mux.HandleFunc(*healthPrefix+"/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc(*healthPrefix+"/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	options := rate_limiter.MiddlewareOptions{KeyFunc: keyFunc}
+	limited := rate_limiter.NewBandwidthMiddleware(router, proxy, options)
+	mux.Handle("/", rate_limiter.NewHTTPMiddleware(router, limited, options))
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go watchConfig(router, *configPath, *reloadInterval)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				reload(router)
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			server.Shutdown(ctx)
+			cancel()
+			return
+		}
+	}()
+
+	log.Printf("proxying %s to %s", *addr, upstreamURL)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+func parseKeyFunc(key string) (rate_limiter.KeyFunc, error) {
+	switch {
+	case key == "ip":
+		return rate_limiter.KeyByRemoteAddr, nil
+	case key == "none":
+		return nil, nil
+	case strings.HasPrefix(key, "header:") && len(key) > len("header:"):
+		return rate_limiter.KeyByHeader(strings.TrimPrefix(key, "header:")), nil
+	default:
+		return nil, errors.New("invalid -key, expected ip, header:<Name> or none")
+	}
+}
+
+// watchConfig reloads the router whenever the content of the config file
+// changes. The file is only read when its modification time changes, and
+// saving it unchanged does not reload it.
+func watchConfig(router *rate_limiter.ReloadableRouter, path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	var lastModified time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastModified = info.ModTime()
+	}
+	lastHash, _ := hashFile(path)
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(lastModified) {
+			continue
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			continue
+		}
+		lastModified = info.ModTime()
+		if hash == lastHash {
+			continue
+		}
+		lastHash = hash
+		reload(router)
+	}
+}
+
+func hashFile(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func reload(router *rate_limiter.ReloadableRouter) {
+	if err := router.Reload(); err != nil {
+		log.Printf("config reload failed, keeping previous routes: %v", err)
+		return
+	}
+	log.Printf("config reloaded")
+}
+
+func logRouteWarnings(issues []rate_limiter.RouteIssue) {
+	for _, issue := range issues {
+		if issue.Severity == rate_limiter.RouteIssueWarning {
+			log.Print(issue)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Command ratelimit-rls runs an Envoy global rate limit service backed by the
+// descriptor configuration in a JSON or YAML file.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"google.golang.org/grpc"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+	"github.com/Ruannilton/go-rate-limiter/envoyrls"
+)
+
+func main() {
+	configPath := flag.String("config", "ratelimit.yaml", "descriptor configuration file (.json, .yaml or .yml)")
+	addr := flag.String("addr", ":8081", "gRPC listen address")
+	flag.Parse()
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+
+	builder := rate_limiter.NewDescriptorRouterBuilder()
+	if filepath.Ext(*configPath) == ".json" {
+		err = builder.LoadFromJson(data)
+	} else {
+		err = builder.LoadFromYaml(data)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	router, err := builder.Build()
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	envoyrls.NewServer(router).Register(server)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.GracefulStop()
+	}()
+
+	log.Printf("rate limit service listening on %s", listener.Addr())
+	if err := server.Serve(listener); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+// Command ratelimit-server serves rate limit decisions for the routes in a
+// JSON or YAML file over an HTTP/JSON API, and optionally over the Redis
+// protocol, for services that cannot embed the library.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+func main() {
+	configPath := flag.String("config", "routes.yaml", "route configuration file (.json, .yaml or .yml)")
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	respAddr := flag.String("resp-addr", "", "Redis protocol listen address (disabled when empty)")
+	respReadTimeout := flag.Duration("resp-read-timeout", 5*time.Minute, "close Redis protocol connections idle or sending a command for longer (0 disables)")
+	normalize := flag.String("normalize", "", "comma-separated path normalization options, replacing those of the config file: clean_dot_segments, collapse_slashes, case_insensitive, decode_percent, strip_query")
+	flag.Parse()
+
+	normalization, err := rate_limiter.ParsePathNormalization(*normalize)
+	if err != nil {
+		log.Fatalf("invalid -normalize: %v", err)
+	}
+
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := rate_limiter.NewRouterBuilder(closeChan)
+	if err := builder.LoadFromFile(*configPath); err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if *normalize != "" {
+		builder.SetPathNormalization(normalization)
+	}
+	for _, issue := range builder.Analyze() {
+		if issue.Severity == rate_limiter.RouteIssueWarning {
+			log.Print(issue)
+		}
+	}
+	router, err := builder.Build()
+	if err != nil {
+		log.Fatalf("failed to build routes: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(rate_limiter.CheckPath, rate_limiter.NewCheckHandler(router))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var respServer *rate_limiter.RESPServer
+	if *respAddr != "" {
+		listener, err := net.Listen("tcp", *respAddr)
+		if err != nil {
+			log.Fatalf("failed to listen: %v", err)
+		}
+		respServer = rate_limiter.NewRESPServer(router)
+		respServer.ReadTimeout = *respReadTimeout
+		go func() {
+			if err := respServer.Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Fatal(err)
+			}
+		}()
+		log.Printf("redis protocol listening on %s", listener.Addr())
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if respServer != nil {
+			respServer.Close()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("rate limit decision service listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
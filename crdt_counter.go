@@ -0,0 +1,58 @@
+package rate_limiter
+
+// gCounter is a grow-only counter CRDT: each node only increments its own
+// slot, and replicas merge by taking the maximum of every slot.
+type gCounter map[string]float64
+
+func (g gCounter) value() float64 {
+	total := 0.0
+	for _, count := range g {
+		total += count
+	}
+	return total
+}
+
+func (g gCounter) merge(other gCounter) {
+	for node, count := range other {
+		if count > g[node] {
+			g[node] = count
+		}
+	}
+}
+
+// pnCounter supports decrements by pairing a counter of increments with a
+// counter of decrements.
+type pnCounter struct {
+	Increments gCounter `json:"p"`
+	Decrements gCounter `json:"n"`
+}
+
+func newPNCounter() pnCounter {
+	return pnCounter{
+		Increments: make(gCounter),
+		Decrements: make(gCounter),
+	}
+}
+
+func (c pnCounter) add(node string, delta float64) {
+	if delta >= 0 {
+		c.Increments[node] += delta
+	} else {
+		c.Decrements[node] -= delta
+	}
+}
+
+func (c pnCounter) value() float64 {
+	return c.Increments.value() - c.Decrements.value()
+}
+
+func (c pnCounter) merge(other pnCounter) {
+	c.Increments.merge(other.Increments)
+	c.Decrements.merge(other.Decrements)
+}
+
+func (c pnCounter) clone() pnCounter {
+	clone := newPNCounter()
+	clone.merge(c)
+	return clone
+}
@@ -0,0 +1,45 @@
+package rate_limiter
+
+import "testing"
+
+func TestPNCounter_MergeConverges(t *testing.T) {
+	a := newPNCounter()
+	b := newPNCounter()
+
+	a.add("a", 3)
+	b.add("b", 2)
+	b.add("b", -1)
+
+	a.merge(b)
+	b.merge(a)
+
+	if a.value() != 4 || b.value() != 4 {
+		t.Errorf("Expected both replicas to converge to 4, got %v and %v", a.value(), b.value())
+	}
+}
+
+func TestPNCounter_MergeIsIdempotent(t *testing.T) {
+	a := newPNCounter()
+	b := newPNCounter()
+	b.add("b", 5)
+
+	a.merge(b)
+	a.merge(b)
+	a.merge(b.clone())
+
+	if a.value() != 5 {
+		t.Errorf("Expected repeated merges not to double count, got %v", a.value())
+	}
+}
+
+func TestPNCounter_StaleStateIsIgnored(t *testing.T) {
+	a := newPNCounter()
+	a.add("a", 1)
+	stale := a.clone()
+	a.add("a", 1)
+
+	a.merge(stale)
+	if a.value() != 2 {
+		t.Errorf("Expected stale state not to roll back the counter, got %v", a.value())
+	}
+}
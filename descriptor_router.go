@@ -0,0 +1,182 @@
+package rate_limiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DescriptorEntry is one key/value pair of a descriptor sent by a proxy, such
+// as Envoy's {"remote_address", "10.0.0.1"}.
+type DescriptorEntry struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// EntryDescriptor matches one descriptor entry. An empty Value matches any
+// value, and each distinct value gets its own bucket, dropped like the keys of
+// routes once idle or when too many values are in use. Nested descriptors
+// match the following entries.
+type EntryDescriptor struct {
+	Key               string              `json:"key" yaml:"key"`
+	Value             string              `json:"value,omitempty" yaml:"value,omitempty"`
+	LimiterDescriptor *StrategyDescriptor `json:"limiter,omitempty" yaml:"limiter,omitempty"`
+	Descriptors       []EntryDescriptor   `json:"descriptors,omitempty" yaml:"descriptors,omitempty"`
+}
+
+type DomainDescriptor struct {
+	Domain      string            `json:"domain" yaml:"domain"`
+	Descriptors []EntryDescriptor `json:"descriptors" yaml:"descriptors"`
+}
+
+type descriptorNode struct {
+	children map[string]*descriptorNode
+	limiter  *keyedRateLimiter
+}
+
+// DescriptorRouter matches descriptors made of ordered key/value entries,
+// the model used by Envoy's global rate limit service, instead of paths.
+type DescriptorRouter struct {
+	domains map[string]*descriptorNode
+}
+
+type DescriptorRouterBuilder struct {
+	descriptors map[string]DomainDescriptor
+}
+
+func NewDescriptorRouterBuilder() DescriptorRouterBuilder {
+	return DescriptorRouterBuilder{
+		descriptors: make(map[string]DomainDescriptor),
+	}
+}
+
+func (d *DescriptorRouterBuilder) SetDomain(domain DomainDescriptor) {
+	d.descriptors[domain.Domain] = domain
+}
+
+func (d *DescriptorRouterBuilder) RemoveDomain(domain string) {
+	delete(d.descriptors, domain)
+}
+
+func (d *DescriptorRouterBuilder) GetDomainDescriptors() []DomainDescriptor {
+	descriptors := make([]DomainDescriptor, 0, len(d.descriptors))
+	for _, domain := range d.descriptors {
+		descriptors = append(descriptors, domain)
+	}
+	return descriptors
+}
+
+func (d *DescriptorRouterBuilder) LoadFromJson(jsonData []byte) error {
+	var descriptors []DomainDescriptor
+	if err := json.Unmarshal(jsonData, &descriptors); err != nil {
+		return fmt.Errorf("failed to read JSON: %w", err)
+	}
+	for _, domain := range descriptors {
+		d.SetDomain(domain)
+	}
+	return nil
+}
+
+func (d *DescriptorRouterBuilder) LoadFromYaml(yamlData []byte) error {
+	var descriptors []DomainDescriptor
+	if err := yaml.Unmarshal(yamlData, &descriptors); err != nil {
+		return fmt.Errorf("failed to read YAML: %w", err)
+	}
+	for _, domain := range descriptors {
+		d.SetDomain(domain)
+	}
+	return nil
+}
+
+func (d *DescriptorRouterBuilder) Build() (DescriptorRouter, error) {
+	router := DescriptorRouter{
+		domains: make(map[string]*descriptorNode),
+	}
+	for _, domain := range d.descriptors {
+		if domain.Domain == "" {
+			return DescriptorRouter{}, errors.New("descriptor domain must not be empty")
+		}
+		root := newDescriptorNode()
+		if err := root.setupEntries(domain.Descriptors); err != nil {
+			return DescriptorRouter{}, fmt.Errorf("domain %s: %w", domain.Domain, err)
+		}
+		router.domains[domain.Domain] = root
+	}
+	return router, nil
+}
+
+// HandleDescriptor evaluates the limit configured for the given entries. It
+// reports false when the domain or descriptor has no limit configured, in
+// which case the request is allowed.
+func (d DescriptorRouter) HandleDescriptor(domain string, entries []DescriptorEntry, cost float64) (RequestPipelineResponse, bool) {
+	root, exists := d.domains[domain]
+	if !exists || len(entries) == 0 {
+		return newSyncRequestPipelineResponse(true), false
+	}
+
+	current := root
+	for _, entry := range entries {
+		if child, exists := current.children[descriptorChildKey(entry.Key, entry.Value)]; exists {
+			current = child
+		} else if child, exists := current.children[descriptorChildKey(entry.Key, "")]; exists {
+			current = child
+		} else {
+			return newSyncRequestPipelineResponse(true), false
+		}
+	}
+
+	if current.limiter == nil {
+		return newSyncRequestPipelineResponse(true), false
+	}
+	return current.limiter.evalKey(descriptorBucketKey(entries), cost), true
+}
+
+func newDescriptorNode() *descriptorNode {
+	return &descriptorNode{
+		children: make(map[string]*descriptorNode),
+	}
+}
+
+func (n *descriptorNode) setupEntries(descriptors []EntryDescriptor) error {
+	for _, descriptor := range descriptors {
+		if descriptor.Key == "" {
+			return errors.New("descriptor key must not be empty")
+		}
+		childKey := descriptorChildKey(descriptor.Key, descriptor.Value)
+		if _, exists := n.children[childKey]; exists {
+			return fmt.Errorf("duplicate descriptor %s=%s", descriptor.Key, descriptor.Value)
+		}
+
+		child := newDescriptorNode()
+		if descriptor.LimiterDescriptor != nil {
+			limiter, err := newStrategyKeyedRateLimiter(*descriptor.LimiterDescriptor)
+			if err != nil {
+				return err
+			}
+			child.limiter = limiter
+		}
+		if err := child.setupEntries(descriptor.Descriptors); err != nil {
+			return err
+		}
+		n.children[childKey] = child
+	}
+	return nil
+}
+
+func descriptorChildKey(key, value string) string {
+	if value == "" {
+		return key
+	}
+	return key + "\x00" + value
+}
+
+func descriptorBucketKey(entries []DescriptorEntry) string {
+	parts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		parts = append(parts, entry.Key+"="+entry.Value)
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,136 @@
+package rate_limiter
+
+import (
+	"fmt"
+	"testing"
+)
+
+const testDescriptorConfig = `
+- domain: edge
+  descriptors:
+    - key: remote_address
+      limiter:
+        type: fixed_window
+        params:
+          capacity: 2
+          reset_interval: 60
+    - key: path
+      value: /login
+      limiter:
+        type: fixed_window
+        params:
+          capacity: 1
+          reset_interval: 60
+      descriptors:
+        - key: user
+          limiter:
+            type: fixed_window
+            params:
+              capacity: 3
+              reset_interval: 60
+`
+
+func newTestDescriptorRouter(t *testing.T) DescriptorRouter {
+	t.Helper()
+	builder := NewDescriptorRouterBuilder()
+	if err := builder.LoadFromYaml([]byte(testDescriptorConfig)); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	router, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Failed to build router: %v", err)
+	}
+	return router
+}
+
+func TestDescriptorRouter_WildcardValueBuckets(t *testing.T) {
+	router := newTestDescriptorRouter(t)
+	first := []DescriptorEntry{{Key: "remote_address", Value: "10.0.0.1"}}
+	second := []DescriptorEntry{{Key: "remote_address", Value: "10.0.0.2"}}
+
+	for i := 0; i < 2; i++ {
+		resp, found := router.HandleDescriptor("edge", first, 1)
+		if !found || !<-resp.Allowed() {
+			t.Fatalf("Expected request %d to match and be allowed", i+1)
+		}
+	}
+	if resp, _ := router.HandleDescriptor("edge", first, 1); <-resp.Allowed() {
+		t.Error("Expected third request from the same address to be blocked")
+	}
+	if resp, _ := router.HandleDescriptor("edge", second, 1); !<-resp.Allowed() {
+		t.Error("Expected another address to have its own bucket")
+	}
+}
+
+func TestDescriptorRouter_NestedDescriptors(t *testing.T) {
+	router := newTestDescriptorRouter(t)
+	login := []DescriptorEntry{{Key: "path", Value: "/login"}}
+	loginUser := []DescriptorEntry{{Key: "path", Value: "/login"}, {Key: "user", Value: "alice"}}
+
+	router.HandleDescriptor("edge", login, 1)
+	if resp, _ := router.HandleDescriptor("edge", login, 1); <-resp.Allowed() {
+		t.Error("Expected /login limit to apply")
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp, found := router.HandleDescriptor("edge", loginUser, 1); !found || !<-resp.Allowed() {
+			t.Errorf("Expected nested descriptor request %d to be allowed", i+1)
+		}
+	}
+	if resp, _ := router.HandleDescriptor("edge", loginUser, 1); <-resp.Allowed() {
+		t.Error("Expected nested descriptor limit to apply")
+	}
+}
+
+func TestDescriptorRouter_NoMatch(t *testing.T) {
+	router := newTestDescriptorRouter(t)
+
+	tests := []struct {
+		domain  string
+		entries []DescriptorEntry
+	}{
+		{"unknown", []DescriptorEntry{{Key: "remote_address", Value: "10.0.0.1"}}},
+		{"edge", []DescriptorEntry{{Key: "path", Value: "/other"}}},
+		{"edge", []DescriptorEntry{{Key: "remote_address", Value: "10.0.0.1"}, {Key: "extra", Value: "x"}}},
+		{"edge", nil},
+	}
+	for _, test := range tests {
+		resp, found := router.HandleDescriptor(test.domain, test.entries, 1)
+		if found {
+			t.Errorf("Expected no limit for %s %v", test.domain, test.entries)
+		}
+		if !<-resp.Allowed() {
+			t.Errorf("Expected unmatched descriptor %v to be allowed", test.entries)
+		}
+	}
+}
+
+func TestDescriptorRouterBuilder_InvalidConfig(t *testing.T) {
+	builder := NewDescriptorRouterBuilder()
+	builder.SetDomain(DomainDescriptor{
+		Domain: "edge",
+		Descriptors: []EntryDescriptor{{
+			Key:               "remote_address",
+			LimiterDescriptor: &StrategyDescriptor{StrategyName: "unknown"},
+		}},
+	})
+	if _, err := builder.Build(); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestDescriptorRouter_BoundsWildcardBuckets(t *testing.T) {
+	router := newTestDescriptorRouter(t)
+	limiter := router.domains["edge"].children["remote_address"].limiter
+	limiter.maxKeys = 100
+
+	for i := 0; i < 1000; i++ {
+		entries := []DescriptorEntry{{Key: "remote_address", Value: fmt.Sprintf("10.0.%d.%d", i/256, i%256)}}
+		if _, found := router.HandleDescriptor("edge", entries, 1); !found {
+			t.Fatal("Expected every address to match the wildcard descriptor")
+		}
+	}
+	if limiter.size() != 100 {
+		t.Errorf("Expected rotating values to be capped at 100 buckets, got %d", limiter.size())
+	}
+}
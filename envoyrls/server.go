@@ -0,0 +1,73 @@
+// Package envoyrls serves a DescriptorRouter as an Envoy global rate limit
+// service (envoy.service.ratelimit.v3.RateLimitService).
+package envoyrls
+
+import (
+	"context"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	router rate_limiter.DescriptorRouter
+}
+
+func NewServer(router rate_limiter.DescriptorRouter) *Server {
+	return &Server{
+		router: router,
+	}
+}
+
+// Register adds the service to a gRPC server.
+func (s *Server) Register(server *grpc.Server) {
+	rlsv3.RegisterRateLimitServiceServer(server, s)
+}
+
+// ShouldRateLimit evaluates every descriptor of the request. The request is
+// over limit if any of its descriptors is. Descriptors without a configured
+// limit are reported as OK.
+func (s *Server) ShouldRateLimit(ctx context.Context, request *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if request.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain must not be empty")
+	}
+
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+		Statuses:    make([]*rlsv3.RateLimitResponse_DescriptorStatus, 0, len(request.GetDescriptors())),
+	}
+
+	for _, descriptor := range request.GetDescriptors() {
+		entries := make([]rate_limiter.DescriptorEntry, 0, len(descriptor.GetEntries()))
+		for _, entry := range descriptor.GetEntries() {
+			entries = append(entries, rate_limiter.DescriptorEntry{Key: entry.GetKey(), Value: entry.GetValue()})
+		}
+
+		resp, _ := s.router.HandleDescriptor(request.GetDomain(), entries, float64(hitsAddend(request, descriptor)))
+		code := rlsv3.RateLimitResponse_OK
+		if !<-resp.Allowed() {
+			code = rlsv3.RateLimitResponse_OVER_LIMIT
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		response.Statuses = append(response.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{Code: code})
+	}
+	return response, nil
+}
+
+// hitsAddend returns the cost of a descriptor: its own hits_addend if set,
+// else the request's, defaulting to 1 as Envoy does.
+func hitsAddend(request *rlsv3.RateLimitRequest, descriptor *ratelimitv3.RateLimitDescriptor) uint64 {
+	if descriptor.GetHitsAddend() != nil {
+		return descriptor.GetHitsAddend().GetValue()
+	}
+	if request.GetHitsAddend() > 0 {
+		return uint64(request.GetHitsAddend())
+	}
+	return 1
+}
@@ -0,0 +1,144 @@
+package envoyrls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+const testConfig = `
+- domain: edge
+  descriptors:
+    - key: remote_address
+      limiter:
+        type: fixed_window
+        params:
+          capacity: 2
+          reset_interval: 60
+`
+
+func newTestClient(t *testing.T) rlsv3.RateLimitServiceClient {
+	t.Helper()
+	builder := rate_limiter.NewDescriptorRouterBuilder()
+	if err := builder.LoadFromYaml([]byte(testConfig)); err != nil {
+		t.Fatal(err)
+	}
+	router, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	NewServer(router).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rlsv3.NewRateLimitServiceClient(conn)
+}
+
+func newTestRequest(address string) *rlsv3.RateLimitRequest {
+	return &rlsv3.RateLimitRequest{
+		Domain: "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{{
+			Entries: []*ratelimitv3.RateLimitDescriptor_Entry{{Key: "remote_address", Value: address}},
+		}},
+	}
+}
+
+func TestServer_OverLimit(t *testing.T) {
+	client := newTestClient(t)
+
+	for i := 0; i < 2; i++ {
+		response, err := client.ShouldRateLimit(context.Background(), newTestRequest("10.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.GetOverallCode() != rlsv3.RateLimitResponse_OK {
+			t.Errorf("Expected request %d to be OK, got %v", i+1, response.GetOverallCode())
+		}
+	}
+
+	response, err := client.ShouldRateLimit(context.Background(), newTestRequest("10.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetOverallCode() != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("Expected OVER_LIMIT, got %v", response.GetOverallCode())
+	}
+	if len(response.GetStatuses()) != 1 || response.GetStatuses()[0].GetCode() != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("Expected one OVER_LIMIT descriptor status, got %v", response.GetStatuses())
+	}
+
+	// Another address has its own bucket
+	response, _ = client.ShouldRateLimit(context.Background(), newTestRequest("10.0.0.2"))
+	if response.GetOverallCode() != rlsv3.RateLimitResponse_OK {
+		t.Errorf("Expected another address to be OK, got %v", response.GetOverallCode())
+	}
+}
+
+func TestServer_HitsAddend(t *testing.T) {
+	client := newTestClient(t)
+
+	request := newTestRequest("10.0.0.3")
+	request.Descriptors[0].HitsAddend = wrapperspb.UInt64(3)
+	response, err := client.ShouldRateLimit(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetOverallCode() != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("Expected a cost of 3 to exceed capacity 2, got %v", response.GetOverallCode())
+	}
+
+	request = newTestRequest("10.0.0.3")
+	request.HitsAddend = 2
+	response, _ = client.ShouldRateLimit(context.Background(), request)
+	if response.GetOverallCode() != rlsv3.RateLimitResponse_OK {
+		t.Errorf("Expected a request hits_addend of 2 to fit, got %v", response.GetOverallCode())
+	}
+}
+
+func TestServer_UnknownDescriptorIsOK(t *testing.T) {
+	client := newTestClient(t)
+
+	request := &rlsv3.RateLimitRequest{
+		Domain: "other",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{{
+			Entries: []*ratelimitv3.RateLimitDescriptor_Entry{{Key: "remote_address", Value: "10.0.0.1"}},
+		}},
+	}
+	response, err := client.ShouldRateLimit(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetOverallCode() != rlsv3.RateLimitResponse_OK {
+		t.Errorf("Expected OK for an unknown domain, got %v", response.GetOverallCode())
+	}
+}
+
+func TestServer_EmptyDomain(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
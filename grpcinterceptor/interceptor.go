@@ -0,0 +1,171 @@
+// Package grpcinterceptor limits gRPC servers with a rate_limiter router.
+//
+// Full method names such as /package.Service/Method are matched as paths, so
+// routes like /*/Method or /package.Service/* apply to every service or every
+// method. Calls are evaluated as POST requests, the method gRPC uses over
+// HTTP/2, to the host of their :authority, with their incoming metadata as
+// headers.
+package grpcinterceptor
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+// KeyFunc extracts the client key of a call. An empty key uses the
+// route-wide limiter.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// KeyByMetadata keys calls by the first value of an incoming metadata entry,
+// such as an API key.
+func KeyByMetadata(name string) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		values := metadata.ValueFromIncomingContext(ctx, name)
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+}
+
+// KeyByPeerAddr keys calls by the IP of the client connection.
+func KeyByPeerAddr(ctx context.Context, fullMethod string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+type Options struct {
+	// KeyFunc selects the bucket of each call. When nil, every call of a
+	// route shares the route-wide limiter.
+	KeyFunc KeyFunc
+	// MessageHandler, when set, limits the messages received on streams. It
+	// is matched by full method name and keyed like calls; messages delayed
+	// by a traffic shaper are held back until released.
+	MessageHandler rate_limiter.KeyedRequestHandler
+}
+
+// UnaryServerInterceptor rejects calls over the limit with
+// codes.ResourceExhausted and a RetryInfo detail.
+func UnaryServerInterceptor(handler rate_limiter.KeyedRequestHandler, options Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		key := callKey(ctx, info.FullMethod, options)
+		if err := evaluate(ctx, handler, info.FullMethod, key); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the opening of streams like
+// UnaryServerInterceptor limits calls, and the rate of received messages if
+// Options.MessageHandler is set.
+func StreamServerInterceptor(handler rate_limiter.KeyedRequestHandler, options Options) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+		ctx := stream.Context()
+		key := callKey(ctx, info.FullMethod, options)
+		if err := evaluate(ctx, handler, info.FullMethod, key); err != nil {
+			return err
+		}
+		if options.MessageHandler == nil {
+			return next(srv, stream)
+		}
+		return next(srv, &limitedServerStream{
+			ServerStream: stream,
+			handler:      options.MessageHandler,
+			fullMethod:   info.FullMethod,
+			key:          key,
+		})
+	}
+}
+
+type limitedServerStream struct {
+	grpc.ServerStream
+	handler    rate_limiter.KeyedRequestHandler
+	fullMethod string
+	key        string
+}
+
+func (l *limitedServerStream) RecvMsg(m any) error {
+	if err := l.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return evaluate(l.Context(), l.handler, l.fullMethod, l.key)
+}
+
+func callKey(ctx context.Context, fullMethod string, options Options) string {
+	if options.KeyFunc == nil {
+		return ""
+	}
+	return options.KeyFunc(ctx, fullMethod)
+}
+
+// callAuthority returns the :authority of an incoming call, so routes can be
+// restricted to the host a client dialed.
+func callAuthority(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, ":authority")
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// callHeader returns the incoming metadata of a call as headers, so routes
+// can match on metadata with header conditions.
+func callHeader(ctx context.Context) http.Header {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := make(http.Header, len(md))
+	for name, values := range md {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	return header
+}
+
+func evaluate(ctx context.Context, handler rate_limiter.KeyedRequestHandler, fullMethod, key string) error {
+	request := rate_limiter.RouteRequest{
+		Host:   callAuthority(ctx),
+		Method: http.MethodPost,
+		Path:   fullMethod,
+		Header: callHeader(ctx),
+	}
+	resp, matched := handler.HandleRouteRequest(request, key, 1)
+	if !matched {
+		return nil
+	}
+
+	select {
+	case allowed := <-resp.Allowed():
+		if allowed {
+			return nil
+		}
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+	if retryAfter := resp.RetryAfter(); retryAfter > 0 {
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
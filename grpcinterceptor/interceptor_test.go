@@ -0,0 +1,179 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	rate_limiter "github.com/Ruannilton/go-rate-limiter"
+)
+
+func newTestRouter(closeChan <-chan struct{}, path string, capacity int) rate_limiter.Router {
+	builder := rate_limiter.NewRouterBuilder(closeChan)
+	builder.SetRoute(rate_limiter.RouteDescriptor{
+		Path: path,
+		LimiterDescriptor: &rate_limiter.StrategyDescriptor{
+			StrategyName: rate_limiter.LimiterStrategyFixedWindow,
+			Params:       map[string]any{"capacity": capacity, "reset_interval": 60.0},
+		},
+	})
+	router, _ := builder.Build()
+	return router
+}
+
+func newTestHealthClient(t *testing.T, router rate_limiter.Router) healthpb.HealthClient {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(router, Options{KeyFunc: KeyByMetadata("x-api-key")})))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor_WildcardService(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client := newTestHealthClient(t, newTestRouter(closeChan, "/*/Check", 2))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "client-a")
+	for i := 0; i < 2; i++ {
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Expected call %d to succeed, got %v", i+1, err)
+		}
+	}
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", err)
+	}
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if retryInfo == nil || retryInfo.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("Expected a RetryInfo detail, got %v", st.Details())
+	}
+
+	// Another key has its own bucket
+	other := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "client-b")
+	if _, err := client.Check(other, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Expected another key to be allowed, got %v", err)
+	}
+}
+
+func TestUnaryServerInterceptor_UnmatchedMethod(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client := newTestHealthClient(t, newTestRouter(closeChan, "/*/Other", 0))
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Expected unmatched method to pass, got %v", err)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream) RecvMsg(m any) error {
+	f.received++
+	return nil
+}
+
+func TestStreamServerInterceptor_MessageRate(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	calls := newTestRouter(closeChan, "/chat.Chat/*", 1)
+	messages := newTestRouter(closeChan, "/chat.Chat/Connect", 3)
+	interceptor := StreamServerInterceptor(calls, Options{MessageHandler: messages})
+	info := &grpc.StreamServerInfo{FullMethod: "/chat.Chat/Connect", IsClientStream: true}
+
+	var recvErr error
+	received := 0
+	stream := &fakeServerStream{ctx: context.Background()}
+	err := interceptor(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+		for {
+			if recvErr = stream.RecvMsg(nil); recvErr != nil {
+				return recvErr
+			}
+			received++
+		}
+	})
+
+	if received != 3 {
+		t.Errorf("Expected 3 messages before the limit, got %d", received)
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected the stream to end with ResourceExhausted, got %v", err)
+	}
+
+	// The stream limit itself allows a single stream
+	err = interceptor(nil, &fakeServerStream{ctx: context.Background()}, info, func(any, grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected the second stream to be rejected, got %v", err)
+	}
+}
+
+func TestStreamServerInterceptor_ShapedMessagesRespectContext(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := rate_limiter.NewRouterBuilder(closeChan)
+	builder.SetRoute(rate_limiter.RouteDescriptor{
+		Path: "/chat.Chat/Connect",
+		TrafficShaperDescriptor: &rate_limiter.StrategyDescriptor{
+			StrategyName: rate_limiter.TrafficStrategyLeakyBucket,
+			Params:       map[string]any{"capacity": 10, "drop_per_second": 1},
+		},
+	})
+	messageRouter, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := rate_limiter.NewRouterBuilder(closeChan)
+	router, err := empty.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := StreamServerInterceptor(router, Options{MessageHandler: messageRouter})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	info := &grpc.StreamServerInfo{FullMethod: "/chat.Chat/Connect"}
+	err = interceptor(nil, &fakeServerStream{ctx: ctx}, info, func(srv any, stream grpc.ServerStream) error {
+		return stream.RecvMsg(nil)
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected a delayed message to give up with the stream context, got %v", err)
+	}
+}
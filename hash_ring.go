@@ -0,0 +1,51 @@
+package rate_limiter
+
+import (
+	"hash/crc32"
+	"slices"
+	"strconv"
+)
+
+const hashRingVirtualNodes = 64
+
+// hashRing assigns keys to nodes by consistent hashing, so that a membership
+// change only moves the keys owned by the nodes that joined or left.
+type hashRing struct {
+	hashes []uint32
+	owners map[uint32]string
+}
+
+func newHashRing(nodes []string) *hashRing {
+	ring := &hashRing{
+		hashes: make([]uint32, 0, len(nodes)*hashRingVirtualNodes),
+		owners: make(map[uint32]string, len(nodes)*hashRingVirtualNodes),
+	}
+	for _, node := range nodes {
+		for i := 0; i < hashRingVirtualNodes; i++ {
+			hash := hashRingKey(node + "#" + strconv.Itoa(i))
+			if _, exists := ring.owners[hash]; exists {
+				continue
+			}
+			ring.owners[hash] = node
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	slices.Sort(ring.hashes)
+	return ring
+}
+
+func (h *hashRing) owner(key string) (string, bool) {
+	if len(h.hashes) == 0 {
+		return "", false
+	}
+	hash := hashRingKey(key)
+	index, _ := slices.BinarySearch(h.hashes, hash)
+	if index == len(h.hashes) {
+		index = 0
+	}
+	return h.owners[h.hashes[index]], true
+}
+
+func hashRingKey(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
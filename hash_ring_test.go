@@ -0,0 +1,53 @@
+package rate_limiter
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHashRing_Distribution(t *testing.T) {
+	nodes := []string{"http://a", "http://b", "http://c"}
+	ring := newHashRing(nodes)
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		owner, ok := ring.owner("key-" + strconv.Itoa(i))
+		if !ok {
+			t.Fatal("Expected an owner")
+		}
+		counts[owner]++
+	}
+
+	for _, node := range nodes {
+		if counts[node] < 500 {
+			t.Errorf("Expected keys to spread across nodes, %s owns %d of 3000", node, counts[node])
+		}
+	}
+}
+
+func TestHashRing_MinimalMovement(t *testing.T) {
+	before := newHashRing([]string{"http://a", "http://b", "http://c"})
+	after := newHashRing([]string{"http://a", "http://b", "http://c", "http://d"})
+
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		key := "key-" + strconv.Itoa(i)
+		oldOwner, _ := before.owner(key)
+		newOwner, _ := after.owner(key)
+		if oldOwner != newOwner {
+			if newOwner != "http://d" {
+				t.Fatalf("Key %s moved between existing nodes %s -> %s", key, oldOwner, newOwner)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1500 {
+		t.Errorf("Expected roughly a quarter of the keys to move, got %d of 3000", moved)
+	}
+}
+
+func TestHashRing_Empty(t *testing.T) {
+	if _, ok := newHashRing(nil).owner("key"); ok {
+		t.Error("Expected no owner on an empty ring")
+	}
+}
@@ -0,0 +1,181 @@
+package rate_limiter
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// KeyedRequestHandler evaluates requests per client key. Router and
+// ReloadableRouter implement it.
+type KeyedRequestHandler interface {
+	HandleRouteRequest(request RouteRequest, key string, cost float64) (RequestPipelineResponse, bool)
+	MatchRoute(request RouteRequest) (RouteMatch, bool)
+}
+
+// KeyFunc extracts the client key of a request. An empty key uses the
+// route-wide limiter.
+type KeyFunc func(r *http.Request) string
+
+// KeyByRemoteAddr keys requests by the client IP of the connection.
+func KeyByRemoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader keys requests by the value of a header, such as an API key or
+// an X-Forwarded-For set by a trusted proxy.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyByPathParam keys requests by a variable of the matched route, such as
+// "tenant" for /:tenant/*.
+func KeyByPathParam(name string) KeyFunc {
+	return func(r *http.Request) string {
+		match, _ := RouteMatchFromContext(r.Context())
+		return match.Params[name]
+	}
+}
+
+type routeMatchContextKey struct{}
+
+// RouteMatchFromContext returns the route matched by NewHTTPMiddleware, for
+// key functions and for the next handler, for example to label metrics by
+// pattern instead of by raw path.
+func RouteMatchFromContext(ctx context.Context) (RouteMatch, bool) {
+	match, ok := ctx.Value(routeMatchContextKey{}).(RouteMatch)
+	return match, ok
+}
+
+func httpRouteRequest(r *http.Request) RouteRequest {
+	return RouteRequest{
+		Host:   r.Host,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header,
+		Query:  r.URL.Query(),
+	}
+}
+
+type MiddlewareOptions struct {
+	// KeyFunc selects the bucket of each request. When nil, every request of
+	// a route shares the route-wide limiter.
+	KeyFunc KeyFunc
+	// OnLimited writes the response for rejected requests. Defaults to a
+	// plain 429 Too Many Requests.
+	OnLimited http.Handler
+}
+
+// NewHTTPMiddleware limits requests to next by matching their path against
+// handler. The matched route is available to the key function and to next
+// with RouteMatchFromContext. Decisions are reported with RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, and rejected requests get a Retry-After header.
+// Requests delayed by a traffic shaper wait until released or until the
+// client goes away.
+func NewHTTPMiddleware(handler KeyedRequestHandler, next http.Handler, options MiddlewareOptions) http.Handler {
+	onLimited := options.OnLimited
+	if onLimited == nil {
+		onLimited = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, matched := handler.MatchRoute(httpRouteRequest(r))
+		if !matched {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), routeMatchContextKey{}, match))
+
+		key := ""
+		if options.KeyFunc != nil {
+			key = options.KeyFunc(r)
+		}
+		resp := match.Handle(key, 1)
+
+		var allowed bool
+		select {
+		case allowed = <-resp.Allowed():
+		case <-r.Context().Done():
+			return
+		}
+
+		if resp.Limit() > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.FormatFloat(resp.Limit(), 'f', -1, 64))
+			w.Header().Set("RateLimit-Remaining", strconv.FormatFloat(math.Floor(resp.Remaining()), 'f', -1, 64))
+			w.Header().Set("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(resp.ResetAfter().Seconds())), 10))
+		}
+		if !allowed {
+			if resp.RetryAfter() > 0 {
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(resp.RetryAfter().Seconds())), 10))
+			}
+			onLimited.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BandwidthHandler returns the bandwidth limiters of a client within a
+// route. Router and ReloadableRouter implement it.
+type BandwidthHandler interface {
+	BandwidthLimiters(request RouteRequest, key string) (upload *BandwidthLimiter, download *BandwidthLimiter, matched bool)
+}
+
+// NewBandwidthMiddleware paces the request and response bodies of routes with
+// a bandwidth limit. Uploads and downloads of each client key are limited
+// separately; transfers over the limit are slowed down, not rejected.
+func NewBandwidthMiddleware(handler BandwidthHandler, next http.Handler, options MiddlewareOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ""
+		if options.KeyFunc != nil {
+			key = options.KeyFunc(r)
+		}
+
+		upload, download, matched := handler.BandwidthLimiters(httpRouteRequest(r), key)
+		if !matched {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = limitedReadCloser{
+				Reader: NewLimitedReader(r.Context(), r.Body, upload),
+				Closer: r.Body,
+			}
+		}
+		next.ServeHTTP(&bandwidthResponseWriter{
+			ResponseWriter: w,
+			writer:         NewLimitedWriter(r.Context(), w, download),
+		}, r)
+	})
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type bandwidthResponseWriter struct {
+	http.ResponseWriter
+	writer io.Writer
+}
+
+func (b *bandwidthResponseWriter) Write(p []byte) (int, error) {
+	return b.writer.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (b *bandwidthResponseWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
@@ -0,0 +1,209 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestMiddleware(closeChan <-chan struct{}, options MiddlewareOptions) http.Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return NewHTTPMiddleware(newTestCheckRouter(closeChan), next, options)
+}
+
+func serveTestRequest(handler http.Handler, path, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestHTTPMiddleware_LimitsAndHeaders(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := newTestMiddleware(closeChan, MiddlewareOptions{KeyFunc: KeyByRemoteAddr})
+
+	for i := 0; i < 3; i++ {
+		recorder := serveTestRequest(handler, "/api/1", "10.0.0.1:1234")
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Expected request %d to reach the handler, got %d", i+1, recorder.Code)
+		}
+		if recorder.Header().Get("RateLimit-Limit") != "3" {
+			t.Errorf("Expected RateLimit-Limit 3, got %q", recorder.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	recorder := serveTestRequest(handler, "/api/1", "10.0.0.1:5678")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+	if recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", recorder.Header().Get("RateLimit-Remaining"))
+	}
+
+	// Another client has its own bucket
+	if recorder := serveTestRequest(handler, "/api/1", "10.0.0.2:1234"); recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected another client to be allowed, got %d", recorder.Code)
+	}
+}
+
+func TestHTTPMiddleware_UnmatchedPassesThrough(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := newTestMiddleware(closeChan, MiddlewareOptions{})
+
+	recorder := serveTestRequest(handler, "/other/path", "10.0.0.1:1234")
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected unmatched request to pass without headers, got %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestHTTPMiddleware_MatchesHost(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	route := newTestFixedWindowRoute("/api/*", nil, 1)
+	route.Host = "*.example.com"
+	builder.SetRoute(route)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := NewHTTPMiddleware(mustBuildRouter(t, &builder), next, MiddlewareOptions{})
+
+	serveTestRequest(handler, "http://acme.example.com/api/1", "10.0.0.1:1234")
+	if recorder := serveTestRequest(handler, "http://acme.example.com:8080/api/1", "10.0.0.1:1234"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the host route to limit the request, got %d", recorder.Code)
+	}
+	if recorder := serveTestRequest(handler, "http://other.test/api/1", "10.0.0.1:1234"); recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected requests to other hosts to pass through, got %d", recorder.Code)
+	}
+}
+
+func TestHTTPMiddleware_KeyByPathParam(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/tenants/:tenant/**", nil, 1))
+	var pattern string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, _ := RouteMatchFromContext(r.Context())
+		pattern = match.Pattern
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := NewHTTPMiddleware(mustBuildRouter(t, &builder), next, MiddlewareOptions{KeyFunc: KeyByPathParam("tenant")})
+
+	if recorder := serveTestRequest(handler, "/tenants/acme/orders/1", "10.0.0.1:1234"); recorder.Code != http.StatusNoContent {
+		t.Fatalf("Expected the first request to reach the handler, got %d", recorder.Code)
+	}
+	if pattern != "/tenants/:tenant/**" {
+		t.Errorf("Expected the next handler to see the pattern, got %q", pattern)
+	}
+	if recorder := serveTestRequest(handler, "/tenants/acme/orders/2", "10.0.0.2:1234"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the tenant's bucket to be shared across paths and clients, got %d", recorder.Code)
+	}
+	if recorder := serveTestRequest(handler, "/tenants/globex/orders/1", "10.0.0.1:1234"); recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected another tenant to have its own bucket, got %d", recorder.Code)
+	}
+}
+
+func TestHTTPMiddleware_MatchConditions(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	export := newTestFixedWindowRoute("/reports/:id", nil, 1)
+	export.Match = []MatchCondition{{Query: "format", Equals: "pdf"}, {Header: "X-Client", Equals: "mobile"}}
+	builder.SetRoute(export)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := NewHTTPMiddleware(mustBuildRouter(t, &builder), next, MiddlewareOptions{})
+
+	serve := func(target, client string) int {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Header.Set("X-Client", client)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	serve("/reports/1?format=pdf", "mobile")
+	if code := serve("/reports/1?format=pdf", "mobile"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the export route to limit the request, got %d", code)
+	}
+	if code := serve("/reports/1?format=pdf", "web"); code != http.StatusNoContent {
+		t.Errorf("Expected requests failing the conditions to pass through, got %d", code)
+	}
+	if code := serve("/reports/1", "mobile"); code != http.StatusNoContent {
+		t.Errorf("Expected requests failing the conditions to pass through, got %d", code)
+	}
+}
+
+func TestHTTPMiddleware_CustomLimitedResponse(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	handler := newTestMiddleware(closeChan, MiddlewareOptions{
+		KeyFunc: KeyByHeader("X-API-Key"),
+		OnLimited: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	})
+
+	var recorder *httptest.ResponseRecorder
+	for i := 0; i < 4; i++ {
+		request := httptest.NewRequest(http.MethodGet, "/api/1", nil)
+		request.Header.Set("X-API-Key", "secret")
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+	}
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected custom limited response, got %d", recorder.Code)
+	}
+}
+
+func TestBandwidthMiddleware_PacesBodies(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(RouteDescriptor{
+		Path:                "/files/*",
+		BandwidthDescriptor: &BandwidthDescriptor{Rate: 10000, Burst: 1000},
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(make([]byte, 2000))
+	})
+	handler := NewBandwidthMiddleware(mustBuildRouter(t, &builder), next, MiddlewareOptions{KeyFunc: KeyByRemoteAddr})
+
+	start := time.Now()
+	request := httptest.NewRequest(http.MethodPost, "/files/a", bytes.NewReader(make([]byte, 2000)))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Body.Len() != 2000 {
+		t.Fatalf("Expected the full response, got %d bytes", recorder.Body.Len())
+	}
+	// 1000 bytes over the burst in each direction, at 10000 bytes per second
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the upload and download to be paced, took %v", elapsed)
+	}
+
+	start = time.Now()
+	recorder = serveTestRequest(handler, "/other", "10.0.0.1:1234")
+	if recorder.Body.Len() != 2000 {
+		t.Fatalf("Expected the full response, got %d bytes", recorder.Body.Len())
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected unmatched paths not to be paced, took %v", elapsed)
+	}
+}
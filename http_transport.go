@@ -0,0 +1,95 @@
+package rate_limiter
+
+import "net/http"
+
+type TransportOptions struct {
+	// Base performs the requests once they are allowed. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+	// KeyFunc selects the bucket of each request. When nil, every request of
+	// a route shares the route-wide limiter.
+	KeyFunc KeyFunc
+	// RetryPolicy retries failed requests within a retry budget. When nil,
+	// requests are not retried.
+	RetryPolicy *RetryPolicy
+}
+
+// Transport is an http.RoundTripper that throttles outbound requests, such as
+// calls to a third-party API with a strict quota. Requests are matched by
+// host and path, so a route with host "api.example.com" and path "/v1/*"
+// limits every call to that host under /v1. Instead of failing, a request over
+// the limit waits until the limiter would allow it, or until its context is
+// done. A Transport is safe for concurrent use.
+//
+// Requests no route matches this way are matched again against routes written
+// in the former form, with the host as the first path segment, such as
+// "/api.example.com/v1/*" for any host.
+type Transport struct {
+	handler     KeyedRequestHandler
+	base        http.RoundTripper
+	keyFunc     KeyFunc
+	retryPolicy *RetryPolicy
+}
+
+func NewTransport(handler KeyedRequestHandler, options TransportOptions) *Transport {
+	base := options.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		handler:     handler,
+		base:        base,
+		keyFunc:     options.KeyFunc,
+		retryPolicy: options.RetryPolicy,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.retryPolicy != nil {
+		return t.roundTripWithRetries(req)
+	}
+	return t.roundTrip(req)
+}
+
+// roundTrip performs a single attempt once the limiter allows it.
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// wait blocks until the request is allowed or its context is done.
+func (t *Transport) wait(req *http.Request) error {
+	request := RouteRequest{
+		Host:   req.URL.Host,
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Header: req.Header,
+		Query:  req.URL.Query(),
+	}
+	if request.Method == "" {
+		request.Method = http.MethodGet
+	}
+	key := ""
+	if t.keyFunc != nil {
+		key = t.keyFunc(req)
+	}
+	return waitAllowed(req.Context(), func() (RequestPipelineResponse, bool) {
+		if resp, found := t.handler.HandleRouteRequest(request, key, 1); found {
+			return resp, true
+		}
+		return t.handler.HandleRouteRequest(legacyTransportRequest(req, request), key, 1)
+	})
+}
+
+// legacyTransportRequest is request in the former form of outbound routes,
+// which put the host before the path instead of matching it as a host.
+func legacyTransportRequest(req *http.Request, request RouteRequest) RouteRequest {
+	request.Host = ""
+	request.Path = "/" + req.URL.Hostname() + req.URL.Path
+	return request
+}
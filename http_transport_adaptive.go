@@ -0,0 +1,212 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUpstreamPause    = time.Second
+	defaultUpstreamMaxPause = 10 * time.Minute
+)
+
+type AdaptiveTransportOptions struct {
+	// Base performs the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// DefaultPause is how long callers are paused after a 429 that reports
+	// neither Retry-After nor a reset. Defaults to 1s.
+	DefaultPause time.Duration
+	// MaxPause caps the pauses upstreams ask for with Retry-After or a reset
+	// time. Defaults to 10 minutes.
+	MaxPause time.Duration
+}
+
+// AdaptiveTransport is an http.RoundTripper that follows the limits reported
+// by upstreams instead of configured ones. Each host gets a token bucket that
+// is recalibrated from the Retry-After, RateLimit and X-RateLimit-* headers
+// of every response: its capacity and tokens follow the reported limit and
+// remaining count, and its refill rate the limit over the window or time to
+// reset. After a 429, or when the remaining count reaches zero, every caller
+// of the host is paused until the reported retry or reset time. Hosts that
+// report nothing are not limited. It can be the Base of a Transport to
+// combine both.
+type AdaptiveTransport struct {
+	base         http.RoundTripper
+	defaultPause time.Duration
+	maxPause     time.Duration
+	upstreams    map[string]*upstreamState
+	mutex        sync.Mutex
+}
+
+// upstreamState is what an AdaptiveTransport knows about one host.
+type upstreamState struct {
+	bucket      *tokenBucketRateLimiter
+	capacity    float64
+	refillRate  float64
+	pausedUntil time.Time
+	inFlight    int
+	mutex       sync.Mutex
+}
+
+func NewAdaptiveTransport(options AdaptiveTransportOptions) *AdaptiveTransport {
+	base := options.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	defaultPause := options.DefaultPause
+	if defaultPause <= 0 {
+		defaultPause = defaultUpstreamPause
+	}
+	maxPause := options.MaxPause
+	if maxPause <= 0 {
+		maxPause = defaultUpstreamMaxPause
+	}
+	return &AdaptiveTransport{
+		base:         base,
+		defaultPause: defaultPause,
+		maxPause:     maxPause,
+		upstreams:    make(map[string]*upstreamState),
+	}
+}
+
+func (a *AdaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstream := a.upstream(req.URL.Host)
+	if err := upstream.wait(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := a.base.RoundTrip(req)
+	upstream.observe(resp, time.Now(), a.defaultPause, a.maxPause)
+	return resp, err
+}
+
+func (a *AdaptiveTransport) upstream(host string) *upstreamState {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	upstream, exists := a.upstreams[host]
+	if !exists {
+		upstream = &upstreamState{}
+		a.upstreams[host] = upstream
+	}
+	return upstream
+}
+
+// wait blocks until the host is not paused and its bucket allows a request,
+// or until ctx is done.
+func (u *upstreamState) wait(ctx context.Context) error {
+	for {
+		u.mutex.Lock()
+		pause := time.Until(u.pausedUntil)
+		if pause <= 0 && !u.pausedUntil.IsZero() {
+			// The pause is over: let one request through to learn the new
+			// state, even if the bucket has not refilled yet.
+			u.pausedUntil = time.Time{}
+			if u.bucket != nil {
+				u.bucket.adjust(u.capacity, max(1, u.bucket.available()), u.refillRate)
+			}
+		}
+		bucket := u.bucket
+		u.mutex.Unlock()
+
+		if pause > 0 {
+			if err := sleepContext(ctx, pause); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if bucket != nil {
+			decision := bucket.evalCost(1)
+			if !<-decision.Allowed() {
+				delay := decision.RetryAfter()
+				if delay <= 0 {
+					delay = defaultWaitRetryInterval
+				}
+				if err := sleepContext(ctx, delay); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		u.mutex.Lock()
+		u.inFlight++
+		u.mutex.Unlock()
+		return nil
+	}
+}
+
+// observe recalibrates the host from a response, which is nil when the
+// request failed.
+func (u *upstreamState) observe(resp *http.Response, now time.Time, defaultPause, maxPause time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.inFlight--
+	if resp == nil {
+		return
+	}
+
+	quota, hasQuota := parseUpstreamQuota(resp.Header, now)
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	if hasQuota {
+		u.calibrate(quota)
+	}
+
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && hasRetryAfter)
+	if !limited && !(hasQuota && quota.remaining < 1) {
+		return
+	}
+
+	var pause time.Duration
+	switch {
+	case hasRetryAfter:
+		pause = retryAfter
+	case hasQuota && quota.hasReset:
+		pause = quota.reset
+	case limited:
+		pause = defaultPause
+	}
+	pause = min(pause, maxPause)
+	if until := now.Add(pause); until.After(u.pausedUntil) {
+		u.pausedUntil = until
+	}
+	if limited && u.bucket != nil {
+		u.bucket.adjust(u.capacity, 0, u.refillRate)
+	}
+}
+
+// calibrate matches the bucket to a reported quota. Requests still in flight
+// are not counted by the upstream yet, so they are taken from the reported
+// remaining count. Must be called with the mutex held.
+func (u *upstreamState) calibrate(quota upstreamQuota) {
+	capacity := u.capacity
+	if quota.hasLimit {
+		capacity = quota.limit
+	}
+	capacity = max(capacity, quota.remaining)
+
+	refillRate := u.refillRate
+	if quota.window > 0 {
+		refillRate = capacity / quota.window.Seconds()
+	} else if quota.hasReset && quota.reset > 0 {
+		refillRate = capacity / quota.reset.Seconds()
+	}
+	// Without a rate the bucket would never refill on its own.
+	if refillRate <= 0 {
+		return
+	}
+
+	u.capacity = capacity
+	u.refillRate = refillRate
+	tokens := quota.remaining - float64(max(u.inFlight, 0))
+	if u.bucket == nil {
+		u.bucket = newTokenBucketRateLimiter(capacity, refillRate, 1)
+	}
+	u.bucket.adjust(capacity, tokens, refillRate)
+}
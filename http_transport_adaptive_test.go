@@ -0,0 +1,161 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestAdaptiveClient(t *testing.T, options AdaptiveTransportOptions, handler http.HandlerFunc) (*http.Client, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &http.Client{Transport: NewAdaptiveTransport(options)}, server.URL
+}
+
+func TestAdaptiveTransport_PausesAfterTooManyRequests(t *testing.T) {
+	var calls atomic.Int32
+	client, baseURL := newTestAdaptiveClient(t, AdaptiveTransportOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("RateLimit-Reset", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Every caller waits for the reset
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := client.Get(baseURL); err == nil {
+				resp.Body.Close()
+			} else {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected callers to be paused until the reset, took %v", elapsed)
+	}
+}
+
+func TestAdaptiveTransport_DefaultPause(t *testing.T) {
+	var calls atomic.Int32
+	client, baseURL := newTestAdaptiveClient(t, AdaptiveTransportOptions{DefaultPause: 100 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	start := time.Now()
+	resp, err = client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected the default pause after a bare 429, took %v", elapsed)
+	}
+}
+
+func TestAdaptiveTransport_FollowsReportedRemaining(t *testing.T) {
+	var calls atomic.Int32
+	client, baseURL := newTestAdaptiveClient(t, AdaptiveTransportOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		remaining := 2 - calls.Add(1)
+		w.Header().Set("X-RateLimit-Limit", "2")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(max(remaining, 0))))
+		w.Header().Set("X-RateLimit-Reset", "60")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(baseURL)
+		if err != nil {
+			t.Fatalf("Expected request %d to be sent, got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+
+	// The upstream reported no requests left until the reset
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request to wait for the reset, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected the paused request not to reach the upstream, got %d calls", calls.Load())
+	}
+}
+
+func TestAdaptiveTransport_UnreportedHostIsNotLimited(t *testing.T) {
+	client, baseURL := newTestAdaptiveClient(t, AdaptiveTransportOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		resp, err := client.Get(baseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected no throttling, took %v", elapsed)
+	}
+}
+
+func TestAdaptiveTransport_MaxPause(t *testing.T) {
+	var calls atomic.Int32
+	client, baseURL := newTestAdaptiveClient(t, AdaptiveTransportOptions{MaxPause: 100 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1e300")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	start := time.Now()
+	resp, err = client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the pause to be capped at 100ms, took %v", elapsed)
+	}
+}
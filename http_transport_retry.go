@@ -0,0 +1,144 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultRetryMaxBackoff  = 10 * time.Second
+)
+
+// RetryPolicy makes a Transport retry failed requests while its budget
+// allows. Retries go through the route limiter like any other request.
+type RetryPolicy struct {
+	// Budget bounds retries to a share of successful requests, that is
+	// requests answered without error and with a status below 400. When nil,
+	// retries are only bounded by MaxAttempts.
+	Budget *RetryBudget
+	// MaxAttempts is the number of attempts per request, including the
+	// first one. Defaults to 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for every
+	// following one. A longer Retry-After from the upstream takes precedence,
+	// and a Retry-After longer than MaxBackoff stops the retries. Defaults to
+	// 100ms.
+	Backoff time.Duration
+	// MaxBackoff caps the doubled backoff. Defaults to 10s.
+	MaxBackoff time.Duration
+	// ShouldRetry decides whether an attempt failed. Defaults to
+	// DefaultShouldRetry.
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+// DefaultShouldRetry retries transport errors other than a cancelled or
+// expired context, and 429, 502, 503 and 504 responses.
+func DefaultShouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *Transport) roundTripWithRetries(req *http.Request) (*http.Response, error) {
+	policy := t.retryPolicy
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	shouldRetry := policy.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = DefaultShouldRetry
+	}
+
+	ctx := req.Context()
+	attempt := req
+	for attempts := 1; ; attempts++ {
+		resp, err := t.roundTrip(attempt)
+		if !shouldRetry(resp, err) {
+			if err == nil && resp.StatusCode < http.StatusBadRequest && policy.Budget != nil {
+				policy.Budget.RecordSuccess()
+			}
+			return resp, err
+		}
+		if attempts >= maxAttempts || !isReplayableRequest(req) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := retryBackoff(backoff, maxBackoff, attempts)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				// Waits longer than MaxBackoff are left to the caller
+				if retryAfter > maxBackoff {
+					return resp, err
+				}
+				delay = max(delay, retryAfter)
+			}
+		}
+		if policy.Budget != nil && !policy.Budget.CanRetry() {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		attempt = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+	}
+}
+
+// retryBackoff doubles backoff for every attempt after the first one, up to
+// maxBackoff.
+func retryBackoff(backoff, maxBackoff time.Duration, attempts int) time.Duration {
+	delay := min(backoff, maxBackoff)
+	for i := 1; i < attempts; i++ {
+		if delay > maxBackoff/2 {
+			return maxBackoff
+		}
+		delay *= 2
+	}
+	return delay
+}
+
+// isReplayableRequest follows the rules net/http uses for its own retries:
+// the body can be read again and the request is idempotent.
+func isReplayableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasIdempotencyKey := req.Header["Idempotency-Key"]
+	_, hasXIdempotencyKey := req.Header["X-Idempotency-Key"]
+	return hasIdempotencyKey || hasXIdempotencyKey
+}
@@ -0,0 +1,164 @@
+package rate_limiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRetryClient(t *testing.T, policy *RetryPolicy, failures int32) (*http.Client, string, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	closeChan := make(chan struct{})
+	t.Cleanup(func() { close(closeChan) })
+	builder := NewRouterBuilder(closeChan)
+	transport := NewTransport(mustBuildRouter(t, &builder), TransportOptions{RetryPolicy: policy})
+	return &http.Client{Transport: transport}, server.URL, calls
+}
+
+func TestTransport_RetriesWithinBudget(t *testing.T) {
+	policy := &RetryPolicy{Budget: newRetryBudget(0, 5, time.Minute), Backoff: time.Millisecond}
+	client, baseURL, calls := newTestRetryClient(t, policy, 2)
+
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the third attempt to succeed, got %d", resp.StatusCode)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestTransport_RetryBudgetExhausted(t *testing.T) {
+	policy := &RetryPolicy{Budget: newRetryBudget(0, 1, time.Minute), Backoff: time.Millisecond}
+	client, baseURL, calls := newTestRetryClient(t, policy, 10)
+
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 2 {
+		t.Errorf("Expected a single retry from the budget, got %d attempts", calls.Load())
+	}
+
+	resp, err = client.Get(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 3 {
+		t.Errorf("Expected no retry with an empty budget, got %d after %d attempts", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestTransport_DoesNotRetryNonReplayable(t *testing.T) {
+	policy := &RetryPolicy{Budget: newRetryBudget(0, 5, time.Minute), Backoff: time.Millisecond}
+	client, baseURL, calls := newTestRetryClient(t, policy, 1)
+
+	resp, err := client.Post(baseURL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("Expected a POST not to be retried, got %d attempts", calls.Load())
+	}
+
+	request, _ := http.NewRequest(http.MethodPut, baseURL, strings.NewReader("payload"))
+	calls.Store(0)
+	resp, err = client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || calls.Load() != 2 {
+		t.Errorf("Expected a PUT with a replayable body to be retried, got %d after %d attempts", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{8, 10 * time.Second},
+		{70, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if delay := retryBackoff(100*time.Millisecond, 10*time.Second, tt.attempts); delay != tt.expected {
+			t.Errorf("Expected attempt %d to wait %v, got %v", tt.attempts, tt.expected, delay)
+		}
+	}
+	if delay := retryBackoff(time.Minute, time.Second, 1); delay != time.Second {
+		t.Errorf("Expected the first backoff to be capped too, got %v", delay)
+	}
+}
+
+func TestTransport_ErrorsDoNotFundRetries(t *testing.T) {
+	budget := newRetryBudget(1, 0, time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	closeChan := make(chan struct{})
+	t.Cleanup(func() { close(closeChan) })
+	builder := NewRouterBuilder(closeChan)
+	client := &http.Client{Transport: NewTransport(mustBuildRouter(t, &builder), TransportOptions{RetryPolicy: &RetryPolicy{Budget: budget}})}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if budget.CanRetry() {
+		t.Error("Expected error responses not to count as successes")
+	}
+}
+
+func TestTransport_DoesNotWaitBeyondMaxBackoff(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	closeChan := make(chan struct{})
+	t.Cleanup(func() { close(closeChan) })
+	builder := NewRouterBuilder(closeChan)
+	client := &http.Client{Transport: NewTransport(mustBuildRouter(t, &builder), TransportOptions{RetryPolicy: &RetryPolicy{}})}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("Expected a Retry-After beyond MaxBackoff to stop the retries, got %d after %d attempts", resp.StatusCode, calls.Load())
+	}
+}
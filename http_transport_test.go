@@ -0,0 +1,161 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTransportClient(t *testing.T, closeChan <-chan struct{}, refillRate float64) (*http.Client, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	host := mustParseURL(t, server.URL).Hostname()
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(RouteDescriptor{
+		Host: host,
+		Path: "/quota/*",
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyTokenBucket,
+			Params:       map[string]any{"capacity": 1, "refill_rate": refillRate, "request_cost": 1},
+		},
+	})
+	client := &http.Client{Transport: NewTransport(mustBuildRouter(t, &builder), TransportOptions{})}
+	return client, server.URL
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestTransport_WaitsInsteadOfRejecting(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client, baseURL := newTestTransportClient(t, closeChan, 20)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(baseURL + "/quota/items")
+		if err != nil {
+			t.Fatalf("Expected request %d to succeed, got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected requests to be spaced by the refill rate, took %v", elapsed)
+	}
+}
+
+func TestTransport_ContextCancelsWait(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client, baseURL := newTestTransportClient(t, closeChan, 0.1)
+
+	resp, err := client.Get(baseURL + "/quota/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/quota/items", nil)
+	start := time.Now()
+	if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestTransport_UnmatchedPassesThrough(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client, baseURL := newTestTransportClient(t, closeChan, 0.1)
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(baseURL + "/free")
+		if err != nil {
+			t.Fatalf("Expected unmatched request %d to pass, got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestTransport_LegacyHostPathRoutes(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	host := mustParseURL(t, server.URL).Hostname()
+
+	// Routes of the former form put the host before the path.
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(RouteDescriptor{
+		Path: "/" + host + "/quota/*",
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyTokenBucket,
+			Params:       map[string]any{"capacity": 1, "refill_rate": 0.1, "request_cost": 1},
+		},
+	})
+	client := &http.Client{Transport: NewTransport(mustBuildRouter(t, &builder), TransportOptions{})}
+
+	resp, err := client.Get(server.URL + "/quota/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/quota/items", nil)
+	if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the legacy route to limit the request, got %v", err)
+	}
+}
+
+func TestTransport_ConcurrentCallers(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	client, baseURL := newTestTransportClient(t, closeChan, 50)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(baseURL + "/quota/items")
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Expected every caller to eventually succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected callers to share the bucket, took %v", elapsed)
+	}
+}
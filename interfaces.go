@@ -2,6 +2,7 @@ package rate_limiter
 
 type iRateLimiter interface {
 	eval() RequestPipelineResponse
+	evalCost(cost float64) RequestPipelineResponse
 }
 
 type iTrafficShapeAlgorithm interface {
@@ -8,7 +8,7 @@ import (
 )
 
 type fixedWindowRateLimiter struct {
-	counter       int
+	counter       float64
 	capacity      int
 	mutex         sync.Mutex
 	lastReset     time.Time
@@ -26,17 +26,23 @@ func newFixedWindowRateLimiter(capacity int, resetInterval time.Duration) *fixed
 }
 
 func (f *fixedWindowRateLimiter) eval() RequestPipelineResponse {
+	return f.evalCost(1)
+}
+
+func (f *fixedWindowRateLimiter) evalCost(cost float64) RequestPipelineResponse {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 	if time.Since(f.lastReset) >= f.resetInterval {
 		f.counter = 0
 		f.lastReset = time.Now()
 	}
-	if f.counter < f.capacity {
-		f.counter++
-		return newSyncRequestPipelineResponse(true)
+	capacity := float64(f.capacity)
+	resetAfter := f.resetInterval - time.Since(f.lastReset)
+	if f.counter+cost <= capacity {
+		f.counter += cost
+		return newSyncRequestPipelineResponse(true).withQuota(capacity, capacity-f.counter, resetAfter, 0)
 	} else {
-		return newSyncRequestPipelineResponse(false)
+		return newSyncRequestPipelineResponse(false).withQuota(capacity, capacity-f.counter, resetAfter, resetAfter)
 	}
 }
 
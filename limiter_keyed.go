@@ -0,0 +1,144 @@
+package rate_limiter
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultKeyedLimiterMaxKeys bounds the keys a keyedRateLimiter tracks. Keys
+// come from clients, so without a bound rotating them would grow memory
+// without limit.
+const defaultKeyedLimiterMaxKeys = 100_000
+
+// keyedStore keeps a value per key, created by factory on first use.
+//
+// A key idle for idleTTL is dropped, which loses nothing when idleTTL is the
+// time its value takes to return to its initial state. When more than maxKeys
+// keys are in use, the least recently used one is dropped early and starts
+// over with a fresh value.
+//
+// keyIdleTTL, when set, gives each key its own idle TTL in place of idleTTL.
+// Idle keys are then dropped from the least recently used only until one of
+// them is still within its TTL, which the bound on keys makes up for.
+//
+// release, when set, is called with the value of every dropped key.
+type keyedStore[V any] struct {
+	factory    func(key string) V
+	idleTTL    time.Duration
+	keyIdleTTL func(key string) time.Duration
+	release    func(value V)
+	maxKeys    int
+	entries    map[string]*list.Element
+	recent     *list.List
+	mutex      sync.Mutex
+}
+
+type keyedStoreEntry[V any] struct {
+	key      string
+	value    V
+	idleTTL  time.Duration
+	lastUsed time.Time
+}
+
+func newKeyedStore[V any](factory func(key string) V, idleTTL time.Duration) *keyedStore[V] {
+	return &keyedStore[V]{
+		factory: factory,
+		idleTTL: idleTTL,
+		maxKeys: defaultKeyedLimiterMaxKeys,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+		mutex:   sync.Mutex{},
+	}
+}
+
+// get returns the value of key, creating it on first use.
+func (k *keyedStore[V]) get(key string) V {
+	now := time.Now()
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.evictIdle(now)
+	var entry *keyedStoreEntry[V]
+	if element, exists := k.entries[key]; exists {
+		k.recent.MoveToFront(element)
+		entry = element.Value.(*keyedStoreEntry[V])
+	} else {
+		entry = &keyedStoreEntry[V]{key: key, value: k.factory(key), idleTTL: k.idleTTL}
+		if k.keyIdleTTL != nil {
+			entry.idleTTL = k.keyIdleTTL(key)
+		}
+		k.entries[key] = k.recent.PushFront(entry)
+		for len(k.entries) > k.maxKeys {
+			k.evict(k.recent.Back())
+		}
+	}
+	entry.lastUsed = now
+	return entry.value
+}
+
+// size returns the number of keys tracked.
+func (k *keyedStore[V]) size() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return len(k.entries)
+}
+
+// evictIdle drops the keys idle for their TTL, oldest first. Must be called
+// with the mutex held.
+func (k *keyedStore[V]) evictIdle(now time.Time) {
+	for element := k.recent.Back(); element != nil; element = k.recent.Back() {
+		entry := element.Value.(*keyedStoreEntry[V])
+		if entry.idleTTL <= 0 || now.Sub(entry.lastUsed) < entry.idleTTL {
+			return
+		}
+		k.evict(element)
+	}
+}
+
+// evict drops the key of element. Must be called with the mutex held.
+func (k *keyedStore[V]) evict(element *list.Element) {
+	entry := k.recent.Remove(element).(*keyedStoreEntry[V])
+	delete(k.entries, entry.key)
+	if k.release != nil {
+		k.release(entry.value)
+	}
+}
+
+// keyedRateLimiter keeps an independent limiter per key, created by factory on
+// first use. Factories must not fail; callers validate the configuration
+// before building one. Keys are dropped as described on keyedStore.
+type keyedRateLimiter struct {
+	*keyedStore[iRateLimiter]
+}
+
+// releasableRateLimiter is implemented by limiters holding resources beyond
+// their own memory, released when their key is dropped.
+type releasableRateLimiter interface {
+	release()
+}
+
+func newKeyedRateLimiter(factory func(key string) iRateLimiter, idleTTL time.Duration) *keyedRateLimiter {
+	store := newKeyedStore(factory, idleTTL)
+	store.release = func(limiter iRateLimiter) {
+		if releasable, ok := limiter.(releasableRateLimiter); ok {
+			releasable.release()
+		}
+	}
+	return &keyedRateLimiter{keyedStore: store}
+}
+
+// newStrategyKeyedRateLimiter applies one in-memory strategy independently to
+// every key.
+func newStrategyKeyedRateLimiter(strategy StrategyDescriptor) (*keyedRateLimiter, error) {
+	factory, err := newRateLimiterFactory(strategy)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyedRateLimiter(func(string) iRateLimiter {
+		return factory()
+	}, strategyIdleTTL(strategy)), nil
+}
+
+func (k *keyedRateLimiter) evalKey(key string, cost float64) RequestPipelineResponse {
+	return k.get(key).evalCost(cost)
+}
@@ -0,0 +1,94 @@
+package rate_limiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeyedRateLimiter_EvictsIdleKeys(t *testing.T) {
+	keyed, err := newStrategyKeyedRateLimiter(StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 1, "reset_interval": 0.05},
+	})
+	if err != nil {
+		t.Fatalf("Expected a valid strategy, got %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		keyed.evalKey(fmt.Sprintf("client-%d", i), 1)
+	}
+	if keyed.size() != 10 {
+		t.Fatalf("Expected 10 keys, got %d", keyed.size())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if resp := keyed.evalKey("client-0", 1); !<-resp.Allowed() {
+		t.Error("Expected an evicted key to start over")
+	}
+	if keyed.size() != 1 {
+		t.Errorf("Expected idle keys to be evicted, got %d keys", keyed.size())
+	}
+}
+
+func TestKeyedRateLimiter_BoundsKeys(t *testing.T) {
+	keyed, _ := newStrategyKeyedRateLimiter(StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 1, "reset_interval": 60.0},
+	})
+	keyed.maxKeys = 3
+
+	keyed.evalKey("a", 1)
+	keyed.evalKey("b", 1)
+	keyed.evalKey("c", 1)
+	keyed.evalKey("a", 1)
+	keyed.evalKey("d", 1)
+
+	if keyed.size() != 3 {
+		t.Fatalf("Expected the keys to be capped at 3, got %d", keyed.size())
+	}
+	// b was the least recently used key
+	if resp := keyed.evalKey("b", 1); !<-resp.Allowed() {
+		t.Error("Expected the least recently used key to be evicted")
+	}
+	if resp := keyed.evalKey("d", 1); <-resp.Allowed() {
+		t.Error("Expected a recently used key to keep its state")
+	}
+}
+
+func TestKeyedRateLimiter_ReleasesBatchedLimiters(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	backend := &fakeBackend{allowed: true}
+	route := newRemoteTestRoute(FailurePolicyOpen, 10)
+	route.BackendDescriptor.Sync = &BackendSyncDescriptor{Interval: 3600, MaxOvershoot: 5}
+	factory, err := newRemoteRateLimiterFactory(*route.LimiterDescriptor, *route.BackendDescriptor, map[string]Backend{"shared": backend}, closeChan)
+	if err != nil {
+		t.Fatalf("Expected a valid backend descriptor, got %v", err)
+	}
+	keyed := newKeyedRateLimiter(factory, time.Hour)
+	keyed.maxKeys = 2
+
+	for i := 0; i < 5; i++ {
+		keyed.evalKey(fmt.Sprintf("client-%d", i), 1)
+	}
+
+	group := keyed.entries["client-4"].Value.(*keyedStoreEntry[iRateLimiter]).value.(*batchedRemoteRateLimiter).group
+	group.mutex.Lock()
+	active, released := len(group.limiters), len(group.released)
+	group.mutex.Unlock()
+	if active != 2 || released != 3 {
+		t.Fatalf("Expected 2 active and 3 released limiters in the shared group, got %d and %d", active, released)
+	}
+
+	// Released limiters still report their pending requests once
+	group.sync()
+	if backend.callCount() != 5 {
+		t.Errorf("Expected every admitted request to be reported, got %d calls", backend.callCount())
+	}
+	group.sync()
+	if backend.callCount() != 5 {
+		t.Errorf("Expected released limiters to leave the group, got %d calls", backend.callCount())
+	}
+}
@@ -0,0 +1,65 @@
+package rate_limiter
+
+import (
+	"context"
+	"time"
+)
+
+// failureFallback answers in place of a backend that is failing, according to
+// the route failure policy.
+type failureFallback struct {
+	policy FailurePolicy
+	local  iRateLimiter
+}
+
+func (f failureFallback) evalCost(cost float64) RequestPipelineResponse {
+	switch f.policy {
+	case FailurePolicyClosed:
+		return newSyncRequestPipelineResponse(false)
+	case FailurePolicyLocal:
+		return f.local.evalCost(cost)
+	default:
+		return newSyncRequestPipelineResponse(true)
+	}
+}
+
+type remoteRateLimiter struct {
+	backend  Backend
+	key      string
+	strategy StrategyDescriptor
+	timeout  time.Duration
+	fallback failureFallback
+	breaker  *circuitBreaker
+}
+
+func newRemoteRateLimiter(backend Backend, key string, strategy StrategyDescriptor, timeout time.Duration, fallback failureFallback, breaker *circuitBreaker) *remoteRateLimiter {
+	return &remoteRateLimiter{
+		backend:  backend,
+		key:      key,
+		strategy: strategy,
+		timeout:  timeout,
+		fallback: fallback,
+		breaker:  breaker,
+	}
+}
+
+func (r *remoteRateLimiter) eval() RequestPipelineResponse {
+	return r.evalCost(1)
+}
+
+func (r *remoteRateLimiter) evalCost(cost float64) RequestPipelineResponse {
+	if !r.breaker.allow() {
+		return r.fallback.evalCost(cost)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	allowed, err := r.backend.Take(ctx, r.key, r.strategy, cost)
+	if err != nil {
+		r.breaker.failure()
+		return r.fallback.evalCost(cost)
+	}
+	r.breaker.success()
+	return newSyncRequestPipelineResponse(allowed)
+}
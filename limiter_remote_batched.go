@@ -0,0 +1,198 @@
+package rate_limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// batchedRemoteRateLimiter answers from local state and talks to the backend
+// once every sync interval. Each sync leases as many tokens as were requested
+// during the previous interval and requests are admitted from that credit.
+// Once the credit runs out, a node admits at most maxOvershoot requests that
+// the backend has not confirmed yet, which bounds the global overshoot to
+// maxOvershoot times the number of nodes.
+type batchedRemoteRateLimiter struct {
+	backend      Backend
+	key          string
+	strategy     StrategyDescriptor
+	timeout      time.Duration
+	fallback     failureFallback
+	breaker      *circuitBreaker
+	maxOvershoot float64
+	pending      float64
+	demand       float64
+	credit       float64
+	exhausted    bool
+	failing      bool
+	mutex        sync.Mutex
+	group        *batchedSyncGroup
+}
+
+func newBatchedRemoteRateLimiter(backend Backend, key string, strategy StrategyDescriptor, timeout time.Duration, fallback failureFallback, breaker *circuitBreaker, maxOvershoot float64, group *batchedSyncGroup) *batchedRemoteRateLimiter {
+	limiter := &batchedRemoteRateLimiter{
+		backend:      backend,
+		key:          key,
+		strategy:     strategy,
+		timeout:      timeout,
+		fallback:     fallback,
+		breaker:      breaker,
+		maxOvershoot: maxOvershoot,
+		mutex:        sync.Mutex{},
+		group:        group,
+	}
+	group.add(limiter)
+	return limiter
+}
+
+// release leaves the sync group once the limiter is no longer used. The
+// group still reports its pending requests once.
+func (b *batchedRemoteRateLimiter) release() {
+	b.group.remove(b)
+}
+
+// batchedSyncGroup runs a single sync loop for the batched limiters of a
+// route, however many keys they are created for.
+type batchedSyncGroup struct {
+	limiters map[*batchedRemoteRateLimiter]struct{}
+	released []*batchedRemoteRateLimiter
+	mutex    sync.Mutex
+}
+
+func newBatchedSyncGroup(syncInterval time.Duration, closeSignal <-chan struct{}) *batchedSyncGroup {
+	group := &batchedSyncGroup{
+		limiters: make(map[*batchedRemoteRateLimiter]struct{}),
+		mutex:    sync.Mutex{},
+	}
+	go func(group *batchedSyncGroup) {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				group.sync()
+			case <-closeSignal:
+				group.sync()
+				return
+			}
+		}
+	}(group)
+	return group
+}
+
+func (g *batchedSyncGroup) add(limiter *batchedRemoteRateLimiter) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.limiters[limiter] = struct{}{}
+}
+
+func (g *batchedSyncGroup) remove(limiter *batchedRemoteRateLimiter) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if _, exists := g.limiters[limiter]; exists {
+		delete(g.limiters, limiter)
+		g.released = append(g.released, limiter)
+	}
+}
+
+func (g *batchedSyncGroup) sync() {
+	g.mutex.Lock()
+	limiters := g.released
+	g.released = nil
+	for limiter := range g.limiters {
+		limiters = append(limiters, limiter)
+	}
+	g.mutex.Unlock()
+
+	for _, limiter := range limiters {
+		limiter.sync()
+	}
+}
+
+func (b *batchedRemoteRateLimiter) eval() RequestPipelineResponse {
+	return b.evalCost(1)
+}
+
+func (b *batchedRemoteRateLimiter) evalCost(cost float64) RequestPipelineResponse {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.demand += cost
+	if b.failing {
+		return b.fallback.evalCost(cost)
+	}
+	if b.credit >= cost {
+		b.credit -= cost
+		return newSyncRequestPipelineResponse(true)
+	}
+	if b.exhausted || b.pending+cost > b.maxOvershoot {
+		return newSyncRequestPipelineResponse(false)
+	}
+	b.pending += cost
+	return newSyncRequestPipelineResponse(true)
+}
+
+func (b *batchedRemoteRateLimiter) sync() {
+	b.mutex.Lock()
+	pending := b.pending
+	// The lease covers the demand of the last interval that the remaining
+	// credit does not. An exhausted or failing backend is probed with a
+	// single token so the limiter notices when it opens again.
+	lease := max(b.demand-b.credit, 0)
+	if b.exhausted || b.failing {
+		lease = max(lease, 1)
+	}
+	b.demand = 0
+	b.mutex.Unlock()
+
+	want := pending + lease
+	if want == 0 {
+		return
+	}
+
+	granted, err := b.take(want)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Granted tokens confirm the unconfirmed requests first and the rest is
+	// kept as credit. Requests the backend did not confirm stay pending and
+	// are reported again on the next sync.
+	confirmed := min(granted, pending)
+	b.pending -= confirmed
+	b.credit += granted - confirmed
+	if err != nil {
+		b.failing = true
+		return
+	}
+	b.failing = false
+	b.exhausted = granted < want
+}
+
+// take asks the backend for amount tokens. A rejected request is halved and
+// retried, so a backend with fewer tokens left than asked for still hands
+// out what it has.
+func (b *batchedRemoteRateLimiter) take(amount float64) (float64, error) {
+	granted := 0.0
+	request := amount
+	for request >= 1 && granted < amount {
+		if !b.breaker.allow() {
+			return granted, errCircuitOpen
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		allowed, err := b.backend.Take(ctx, b.key, b.strategy, request)
+		cancel()
+		if err != nil {
+			b.breaker.failure()
+			return granted, err
+		}
+		b.breaker.success()
+		if allowed {
+			granted += request
+			request = min(request, amount-granted)
+			continue
+		}
+		request = math.Floor(request / 2)
+	}
+	return granted, nil
+}
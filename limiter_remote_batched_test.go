@@ -0,0 +1,156 @@
+package rate_limiter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestBatchedLimiter(backend Backend, maxOvershoot float64, closeChan <-chan struct{}) *batchedRemoteRateLimiter {
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 10, "reset_interval": 60.0},
+	}
+	// A long interval keeps the background loop out of the way; tests call sync directly.
+	return newBatchedRemoteRateLimiter(backend, "/batched", strategy, time.Second, failureFallback{policy: FailurePolicyClosed}, newCircuitBreaker(100, time.Minute), maxOvershoot, newBatchedSyncGroup(time.Hour, closeChan))
+}
+
+func TestBatchedRemoteRateLimiter_BoundsOvershoot(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	backend := &fakeBackend{allowed: true}
+	limiter := newTestBatchedLimiter(backend, 3, closeChan)
+
+	for i := 0; i < 3; i++ {
+		if resp := limiter.eval(); !<-resp.Allowed() {
+			t.Errorf("Expected request %d to be allowed locally", i+1)
+		}
+	}
+	if resp := limiter.eval(); <-resp.Allowed() {
+		t.Error("Expected request beyond max overshoot to be blocked before sync")
+	}
+	if backend.callCount() != 0 {
+		t.Errorf("Expected no backend calls on the hot path, got %d", backend.callCount())
+	}
+
+	limiter.sync()
+	if backend.callCount() != 1 {
+		t.Errorf("Expected a single batched backend call, got %d", backend.callCount())
+	}
+	if resp := limiter.eval(); !<-resp.Allowed() {
+		t.Error("Expected request to be allowed after sync")
+	}
+}
+
+func TestBatchedRemoteRateLimiter_LeasesTokens(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	strategy := StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": 100, "reset_interval": 60.0},
+	}
+	limiter := newBatchedRemoteRateLimiter(NewMemoryBackend(), "/batched", strategy, time.Second, failureFallback{policy: FailurePolicyClosed}, newCircuitBreaker(100, time.Minute), 2, newBatchedSyncGroup(time.Hour, closeChan))
+
+	// The first interval only admits unconfirmed requests, but its demand
+	// sizes the lease taken on the next sync
+	admitted := 0
+	for i := 0; i < 150; i++ {
+		if resp := limiter.eval(); <-resp.Allowed() {
+			admitted++
+		}
+	}
+	if admitted != 2 {
+		t.Fatalf("Expected max overshoot to cap requests before the first sync, got %d", admitted)
+	}
+
+	limiter.sync()
+	for i := 0; i < 150; i++ {
+		if resp := limiter.eval(); <-resp.Allowed() {
+			admitted++
+		}
+	}
+	if admitted != 100 {
+		t.Errorf("Expected the backend limit to be reached within one interval, got %d", admitted)
+	}
+	if limiter.pending != 0 {
+		t.Errorf("Expected the overshoot to be confirmed by the lease, pending=%v", limiter.pending)
+	}
+}
+
+func TestBatchedRemoteRateLimiter_ExhaustedBackend(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	backend := &fakeBackend{allowed: false}
+	limiter := newTestBatchedLimiter(backend, 5, closeChan)
+
+	limiter.eval()
+	limiter.sync()
+
+	if resp := limiter.eval(); <-resp.Allowed() {
+		t.Error("Expected requests to be blocked once the backend reports exhaustion")
+	}
+
+	// A successful probe reopens the bucket and is kept as credit
+	backend.mutex.Lock()
+	backend.allowed = true
+	backend.mutex.Unlock()
+	limiter.sync()
+
+	if resp := limiter.eval(); !<-resp.Allowed() {
+		t.Error("Expected request to be allowed after a successful probe")
+	}
+	if limiter.pending != 0 {
+		t.Errorf("Expected credited request not to be reported again, pending=%v", limiter.pending)
+	}
+}
+
+func TestBatchedRemoteRateLimiter_FailingBackendUsesPolicy(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	backend := &fakeBackend{err: errors.New("connection refused")}
+	limiter := newTestBatchedLimiter(backend, 5, closeChan)
+
+	limiter.eval()
+	limiter.sync()
+
+	if resp := limiter.eval(); <-resp.Allowed() {
+		t.Error("Expected fail closed policy while the backend is failing")
+	}
+	if limiter.pending != 1 {
+		t.Errorf("Expected unreported delta to be kept, pending=%v", limiter.pending)
+	}
+
+	backend.mutex.Lock()
+	backend.err = nil
+	backend.allowed = true
+	backend.mutex.Unlock()
+	limiter.sync()
+
+	if resp := limiter.eval(); !<-resp.Allowed() {
+		t.Error("Expected recovery once the backend answers again")
+	}
+}
+
+func TestBatchedRemoteRateLimiter_SyncsInBackground(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	backend := &fakeBackend{allowed: true}
+	builder.RegisterBackend("shared", backend)
+	route := newRemoteTestRoute(FailurePolicyOpen, 10)
+	route.BackendDescriptor.Sync = &BackendSyncDescriptor{Interval: 0.05, MaxOvershoot: 2}
+	builder.SetRoute(route)
+	router := mustBuildRouter(t, &builder)
+
+	router.HandleRequest(http.MethodGet, "/remote")
+	time.Sleep(120 * time.Millisecond)
+	if backend.callCount() == 0 {
+		t.Error("Expected the background loop to report the admitted request")
+	}
+}
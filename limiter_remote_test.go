@@ -0,0 +1,162 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	mutex   sync.Mutex
+	calls   int
+	allowed bool
+	err     error
+	delay   time.Duration
+}
+
+func (f *fakeBackend) Take(ctx context.Context, key string, strategy StrategyDescriptor, cost float64) (bool, error) {
+	f.mutex.Lock()
+	f.calls++
+	allowed, err, delay := f.allowed, f.err, f.delay
+	f.mutex.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return allowed, err
+}
+
+func (f *fakeBackend) callCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls
+}
+
+func newRemoteTestRoute(policy FailurePolicy, capacity int) RouteDescriptor {
+	return RouteDescriptor{
+		Path: "/remote",
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyFixedWindow,
+			Params:       map[string]any{"capacity": capacity, "reset_interval": 60.0},
+		},
+		BackendDescriptor: &BackendDescriptor{
+			Name:          "shared",
+			FailurePolicy: policy,
+			Timeout:       0.05,
+			Replicas:      4,
+		},
+	}
+}
+
+func TestRemoteRateLimiter_UsesBackendDecision(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	backend := &fakeBackend{allowed: false}
+	builder := NewRouterBuilder(closeChan)
+	builder.RegisterBackend("shared", backend)
+	builder.SetRoute(newRemoteTestRoute(FailurePolicyOpen, 10))
+	router := mustBuildRouter(t, &builder)
+
+	resp, found := router.HandleRequest(http.MethodGet, "/remote")
+	if !found {
+		t.Fatal("Expected to find route /remote")
+	}
+	if <-resp.Allowed() {
+		t.Error("Expected backend rejection to be honored")
+	}
+	if backend.callCount() != 1 {
+		t.Errorf("Expected 1 backend call, got %d", backend.callCount())
+	}
+}
+
+func TestRemoteRateLimiter_FailurePolicies(t *testing.T) {
+	tests := []struct {
+		policy   FailurePolicy
+		expected bool
+	}{
+		{FailurePolicyOpen, true},
+		{FailurePolicyClosed, false},
+		{FailurePolicyLocal, true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			closeChan := make(chan struct{})
+			defer close(closeChan)
+
+			builder := NewRouterBuilder(closeChan)
+			builder.RegisterBackend("shared", &fakeBackend{err: errors.New("connection refused")})
+			builder.SetRoute(newRemoteTestRoute(test.policy, 10))
+			router := mustBuildRouter(t, &builder)
+
+			resp, _ := router.HandleRequest(http.MethodGet, "/remote")
+			if allowed := <-resp.Allowed(); allowed != test.expected {
+				t.Errorf("Expected allowed=%v, got %v", test.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestRemoteRateLimiter_Timeout(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.RegisterBackend("shared", &fakeBackend{allowed: true, delay: time.Second})
+	builder.SetRoute(newRemoteTestRoute(FailurePolicyClosed, 10))
+	router := mustBuildRouter(t, &builder)
+
+	start := time.Now()
+	resp, _ := router.HandleRequest(http.MethodGet, "/remote")
+	if <-resp.Allowed() {
+		t.Error("Expected timed out request to fail closed")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected timeout to cut the backend call short, took %v", elapsed)
+	}
+}
+
+func TestRemoteRateLimiter_LocalFallbackDividesCapacity(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.RegisterBackend("shared", &fakeBackend{err: errors.New("connection refused")})
+	// Capacity 8 over 4 replicas leaves 2 requests for the local limiter
+	builder.SetRoute(newRemoteTestRoute(FailurePolicyLocal, 8))
+	router := mustBuildRouter(t, &builder)
+
+	allowedCount := 0
+	for i := 0; i < 5; i++ {
+		resp, _ := router.HandleRequest(http.MethodGet, "/remote")
+		if <-resp.Allowed() {
+			allowedCount++
+		}
+	}
+	if allowedCount != 2 {
+		t.Errorf("Expected 2 requests allowed by local fallback, got %d", allowedCount)
+	}
+}
+
+func TestRemoteRateLimiter_CircuitBreakerStopsCalls(t *testing.T) {
+	backend := &fakeBackend{err: errors.New("connection refused")}
+	limiter := newRemoteRateLimiter(backend, "/remote", StrategyDescriptor{}, time.Second, failureFallback{policy: FailurePolicyOpen}, newCircuitBreaker(3, time.Minute))
+
+	for i := 0; i < 10; i++ {
+		resp := limiter.eval()
+		if !<-resp.Allowed() {
+			t.Fatal("Expected request to fail open")
+		}
+	}
+	if backend.callCount() != 3 {
+		t.Errorf("Expected breaker to stop calls after 3 failures, got %d calls", backend.callCount())
+	}
+}
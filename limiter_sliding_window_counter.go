@@ -0,0 +1,102 @@
+package rate_limiter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// slidingWindowCounterLimiter approximates a sliding window by weighting the
+// previous fixed window's count by how much of it still overlaps the sliding
+// window. It needs two counters instead of a log of timestamps.
+type slidingWindowCounterLimiter struct {
+	capacity      float64
+	windowSize    time.Duration
+	currentWindow int64
+	current       float64
+	previous      float64
+	mutex         sync.Mutex
+}
+
+func newSlidingWindowCounterLimiter(capacity int, windowSize time.Duration) *slidingWindowCounterLimiter {
+	return &slidingWindowCounterLimiter{
+		capacity:   float64(capacity),
+		windowSize: windowSize,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (s *slidingWindowCounterLimiter) eval() RequestPipelineResponse {
+	return s.evalCost(1)
+}
+
+func (s *slidingWindowCounterLimiter) evalCost(cost float64) RequestPipelineResponse {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UnixNano()
+	window := now / int64(s.windowSize)
+	switch window - s.currentWindow {
+	case 0:
+	case 1:
+		s.previous = s.current
+		s.current = 0
+	default:
+		s.previous = 0
+		s.current = 0
+	}
+	s.currentWindow = window
+
+	// Both counters are empty once the current window and the next one are
+	// over.
+	resetAfter := 2*s.windowSize - time.Duration(now%int64(s.windowSize))
+	estimate := slidingWindowEstimate(s.previous, s.current, now, s.windowSize)
+	if estimate+cost > s.capacity {
+		retryAfter := slidingWindowCounterRetryAfter(s.previous, s.current, cost, s.capacity, now, s.windowSize)
+		return newSyncRequestPipelineResponse(false).withQuota(s.capacity, s.capacity-estimate, resetAfter, retryAfter)
+	}
+	s.current += cost
+	return newSyncRequestPipelineResponse(true).withQuota(s.capacity, s.capacity-estimate-cost, resetAfter, 0)
+}
+
+// slidingWindowCounterRetryAfter returns how long until the weight of the
+// previous window has decayed enough for cost to fit. If the current window
+// alone leaves no room, the request has to wait for the next window.
+func slidingWindowCounterRetryAfter(previous, current, cost, capacity float64, now int64, windowSize time.Duration) time.Duration {
+	untilNextWindow := windowSize - time.Duration(now%int64(windowSize))
+	room := capacity - current - cost
+	if room < 0 || previous <= 0 {
+		return untilNextWindow
+	}
+	elapsed := float64(now%int64(windowSize)) / float64(windowSize)
+	needed := 1 - room/previous
+	return time.Duration((needed - elapsed) * float64(windowSize))
+}
+
+// slidingWindowEstimate weights the previous epoch-aligned window by the share
+// of it still covered by a window of the given size ending at now.
+func slidingWindowEstimate(previous, current float64, now int64, windowSize time.Duration) float64 {
+	elapsed := float64(now%int64(windowSize)) / float64(windowSize)
+	return previous*(1-elapsed) + current
+}
+
+type slidingWindowCounterLimiterParams struct {
+	Capacity   int
+	WindowSize time.Duration
+}
+
+func getSlidingWindowCounterLimiterParamsFromMap(params map[string]any) (slidingWindowCounterLimiterParams, error) {
+	capacity, capacityOk := getNumberFromMap[int](params, "capacity")
+	if !capacityOk {
+		return slidingWindowCounterLimiterParams{}, errors.New("invalid capacity parameter")
+	}
+	windowSizeSeconds, intervalOk := getNumberFromMap[float64](params, "window_size")
+	if !intervalOk || windowSizeSeconds <= 0 {
+		return slidingWindowCounterLimiterParams{}, errors.New("invalid window_size parameter")
+	}
+	windowSize := time.Duration(windowSizeSeconds * float64(time.Second))
+	return slidingWindowCounterLimiterParams{
+		Capacity:   capacity,
+		WindowSize: windowSize,
+	}, nil
+}
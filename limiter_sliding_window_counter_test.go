@@ -0,0 +1,49 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCounterLimiter_Basic(t *testing.T) {
+	limiter := newSlidingWindowCounterLimiter(3, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if resp := limiter.eval(); !<-resp.Allowed() {
+			t.Errorf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if resp := limiter.eval(); <-resp.Allowed() {
+		t.Error("Expected request over capacity to be blocked")
+	}
+}
+
+func TestSlidingWindowCounterLimiter_WeightsPreviousWindow(t *testing.T) {
+	windowSize := 200 * time.Millisecond
+	limiter := newSlidingWindowCounterLimiter(10, windowSize)
+
+	now := time.Now().UnixNano()
+	limiter.currentWindow = now/int64(windowSize) - 1
+	limiter.current = 10
+
+	// The previous window is still partially counted right after the boundary
+	resp := limiter.evalCost(10)
+	if <-resp.Allowed() {
+		t.Error("Expected the previous window to still weigh on the estimate")
+	}
+
+	// Two windows later it no longer counts at all
+	time.Sleep(2 * windowSize)
+	resp = limiter.evalCost(10)
+	if !<-resp.Allowed() {
+		t.Error("Expected full capacity once the previous window has slid out")
+	}
+}
+
+func TestSlidingWindowEstimate(t *testing.T) {
+	windowSize := time.Second
+	halfway := int64(10*time.Second + 500*time.Millisecond)
+	if estimate := slidingWindowEstimate(10, 2, halfway, windowSize); estimate != 7 {
+		t.Errorf("Expected estimate 7 halfway through the window, got %v", estimate)
+	}
+}
@@ -2,6 +2,7 @@ package rate_limiter
 
 import (
 	"errors"
+	"math"
 	"sync"
 	"time"
 )
@@ -23,27 +24,57 @@ func newSlidingWindowLogLimiter(capacity int, windowSize time.Duration) *sliding
 }
 
 func (s *slidingWindowLogLimiter) eval() RequestPipelineResponse {
+	return s.evalCost(1)
+}
+
+// evalCost logs one timestamp per unit of cost, rounded to the nearest
+// request.
+func (s *slidingWindowLogLimiter) evalCost(cost float64) RequestPipelineResponse {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	now := time.Now()
 	windowStart := now.Add(-s.windowSize)
 
-	newLogs := make([]int64, 0, s.capacity)
-	for _, timestamp := range s.logs {
-		if timestamp >= windowStart.UnixNano() {
-			newLogs = append(newLogs, timestamp)
+	s.logs = pruneTimestampLog(s.logs, windowStart.UnixNano())
+
+	requests := int(math.Round(cost))
+	capacity := float64(s.capacity)
+	if len(s.logs)+requests > s.capacity {
+		// The request fits once enough of the oldest entries leave the window.
+		var retryAfter time.Duration
+		if excess := len(s.logs) + requests - s.capacity; requests <= s.capacity && excess <= len(s.logs) {
+			retryAfter = time.Duration(s.logs[excess-1] + int64(s.windowSize) - now.UnixNano())
 		}
+		return newSyncRequestPipelineResponse(false).withQuota(capacity, capacity-float64(len(s.logs)), s.timeToEmpty(now), retryAfter)
 	}
-	s.logs = newLogs
 
-	if len(s.logs) >= s.capacity {
-		return newSyncRequestPipelineResponse(false)
+	// Add new request timestamps
+	for i := 0; i < requests; i++ {
+		s.logs = append(s.logs, now.UnixNano())
 	}
+	return newSyncRequestPipelineResponse(true).withQuota(capacity, capacity-float64(len(s.logs)), s.timeToEmpty(now), 0)
+}
 
-	// Add new request timestamp
-	s.logs = append(s.logs, now.UnixNano())
-	return newSyncRequestPipelineResponse(true)
+// pruneTimestampLog drops the timestamps older than windowStart, reusing the
+// backing array of logs.
+func pruneTimestampLog(logs []int64, windowStart int64) []int64 {
+	kept := logs[:0]
+	for _, timestamp := range logs {
+		if timestamp >= windowStart {
+			kept = append(kept, timestamp)
+		}
+	}
+	return kept
+}
+
+// timeToEmpty returns how long until every logged request leaves the window.
+// Must be called with the mutex held.
+func (s *slidingWindowLogLimiter) timeToEmpty(now time.Time) time.Duration {
+	if len(s.logs) == 0 {
+		return 0
+	}
+	return time.Duration(s.logs[len(s.logs)-1] + int64(s.windowSize) - now.UnixNano())
 }
 
 type slidingWindowLogLimiterParams struct {
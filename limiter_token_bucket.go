@@ -27,17 +27,50 @@ func newTokenBucketRateLimiter(capacity, refillRate, requestCost float64) *token
 }
 
 func (t *tokenBucketRateLimiter) eval() RequestPipelineResponse {
+	return t.evalCost(1)
+}
+
+func (t *tokenBucketRateLimiter) evalCost(cost float64) RequestPipelineResponse {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	tokensToAdd := float64(time.Since(t.lastRefill).Milliseconds()) * t.refillRateSeconds / 1000
+	tokensToAdd := time.Since(t.lastRefill).Seconds() * t.refillRateSeconds
 	t.lastRefill = time.Now()
 	t.tokens = min(t.capacity, t.tokens+tokensToAdd)
-	if t.tokens >= t.requestCost {
-		t.tokens -= t.requestCost
-		return newSyncRequestPipelineResponse(true)
+	requestCost := t.requestCost * cost
+	if t.tokens >= requestCost {
+		t.tokens -= requestCost
+		return newSyncRequestPipelineResponse(true).withQuota(t.capacity, t.tokens, t.timeToRefill(t.capacity), 0)
 	} else {
-		return newSyncRequestPipelineResponse(false)
+		return newSyncRequestPipelineResponse(false).withQuota(t.capacity, t.tokens, t.timeToRefill(t.capacity), t.timeToRefill(requestCost))
+	}
+}
+
+// adjust replaces the bucket state with the one reported by an upstream that
+// enforces the real limit.
+func (t *tokenBucketRateLimiter) adjust(capacity, tokens, refillRate float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.capacity = capacity
+	t.tokens = min(max(tokens, 0), capacity)
+	t.refillRateSeconds = refillRate
+	t.lastRefill = time.Now()
+}
+
+// available returns the tokens in the bucket, without the refill since the
+// last request.
+func (t *tokenBucketRateLimiter) available() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.tokens
+}
+
+// timeToRefill returns how long until the bucket holds the given amount of
+// tokens, or 0 if it never refills. Must be called with the mutex held.
+func (t *tokenBucketRateLimiter) timeToRefill(tokens float64) time.Duration {
+	if t.refillRateSeconds <= 0 || tokens <= t.tokens {
+		return 0
 	}
+	return time.Duration((tokens - t.tokens) / t.refillRateSeconds * float64(time.Second))
 }
 
 type tokenBucketRateLimiterParams struct {
@@ -93,3 +93,25 @@ func TestTokenBucketRateLimiter_Concurrency(t *testing.T) {
 		t.Errorf("Expected %d allowed requests, got %d", int(capacity), allowedCount)
 	}
 }
+
+func TestTokenBucketRateLimiter_Quota(t *testing.T) {
+	limiter := newTokenBucketRateLimiter(2, 1, 1)
+
+	resp := limiter.eval()
+	if resp.Limit() != 2 || resp.Remaining() != 1 || resp.RetryAfter() != 0 {
+		t.Errorf("Unexpected quota after first request: limit=%v remaining=%v retry=%v", resp.Limit(), resp.Remaining(), resp.RetryAfter())
+	}
+
+	limiter.eval()
+	resp = limiter.eval()
+	if <-resp.Allowed() {
+		t.Fatal("Expected empty bucket to block")
+	}
+	// One token refills in a second at 1 token per second
+	if resp.RetryAfter() <= 900*time.Millisecond || resp.RetryAfter() > time.Second {
+		t.Errorf("Expected retry after about 1s, got %v", resp.RetryAfter())
+	}
+	if resp.ResetAfter() < resp.RetryAfter() {
+		t.Errorf("Expected reset after %v to be at least retry after %v", resp.ResetAfter(), resp.RetryAfter())
+	}
+}
@@ -0,0 +1,320 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+type ListenerAction string
+
+const (
+	// ListenerActionClose closes excess connections as soon as they are
+	// accepted.
+	ListenerActionClose ListenerAction = "close"
+	// ListenerActionDelay holds excess connections until they are allowed or
+	// until the maximum delay, after which they are closed.
+	ListenerActionDelay ListenerAction = "delay"
+)
+
+const (
+	defaultListenerMaxDelay = time.Second
+	// defaultListenerIPv6Prefix groups IPv6 sources by /64, the smallest
+	// network usually assigned to a single client, so one client cannot
+	// rotate addresses to escape its limits.
+	defaultListenerIPv6Prefix = 64
+	// defaultListenerMaxPending bounds the connections held by the delay
+	// action, as each holds a goroutine and a file descriptor.
+	defaultListenerMaxPending = 1024
+)
+
+// ListenerDescriptor limits the connections accepted from each source. A
+// source is the remote IP masked to IPv4Prefix or IPv6Prefix bits, which
+// default to the full IPv4 address and to the /64 network of IPv6 addresses.
+// With the delay action, at most MaxPending connections are held at once
+// across all sources; further excess connections are closed.
+type ListenerDescriptor struct {
+	LimiterDescriptor *StrategyDescriptor `json:"limiter,omitempty" yaml:"limiter,omitempty"`
+	MaxConnections    int                 `json:"max_connections,omitempty" yaml:"max_connections,omitempty"`
+	IPv4Prefix        int                 `json:"ipv4_prefix,omitempty" yaml:"ipv4_prefix,omitempty"`
+	IPv6Prefix        int                 `json:"ipv6_prefix,omitempty" yaml:"ipv6_prefix,omitempty"`
+	Action            ListenerAction      `json:"action,omitempty" yaml:"action,omitempty"`
+	MaxDelay          float64             `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`
+	MaxPending        int                 `json:"max_pending,omitempty" yaml:"max_pending,omitempty"`
+}
+
+// LimitedListener is a net.Listener that limits the rate and the number of
+// concurrent connections of each source before they reach the server.
+// Connections are accepted in the background, so a delayed connection never
+// holds back the others.
+//
+// Per-source state stays bounded: rate limiters of idle sources are dropped,
+// and connection counts are only kept for sources with open connections.
+type LimitedListener struct {
+	net.Listener
+	limiter        *keyedRateLimiter
+	maxConnections int
+	ipv4Prefix     int
+	ipv6Prefix     int
+	action         ListenerAction
+	maxDelay       time.Duration
+	connections    map[string]int
+	pending        chan struct{}
+	released       chan struct{}
+	mutex          sync.Mutex
+	ready          chan net.Conn
+	errs           chan error
+	ctx            context.Context
+	cancel         context.CancelFunc
+	closeOnce      sync.Once
+}
+
+func NewLimitedListener(inner net.Listener, descriptor ListenerDescriptor) (*LimitedListener, error) {
+	ipv4Prefix := descriptor.IPv4Prefix
+	if ipv4Prefix == 0 {
+		ipv4Prefix = 32
+	}
+	ipv6Prefix := descriptor.IPv6Prefix
+	if ipv6Prefix == 0 {
+		ipv6Prefix = defaultListenerIPv6Prefix
+	}
+	if ipv4Prefix < 0 || ipv4Prefix > 32 || ipv6Prefix < 0 || ipv6Prefix > 128 {
+		return nil, errors.New("invalid listener prefix parameters")
+	}
+	if descriptor.MaxConnections < 0 || descriptor.MaxDelay < 0 || descriptor.MaxPending < 0 {
+		return nil, errors.New("invalid listener parameters")
+	}
+
+	action := descriptor.Action
+	switch action {
+	case "":
+		action = ListenerActionClose
+	case ListenerActionClose, ListenerActionDelay:
+	default:
+		return nil, fmt.Errorf("unknown listener action: %s", action)
+	}
+
+	maxDelay := defaultListenerMaxDelay
+	if descriptor.MaxDelay > 0 {
+		maxDelay = time.Duration(descriptor.MaxDelay * float64(time.Second))
+	}
+
+	maxPending := defaultListenerMaxPending
+	if descriptor.MaxPending > 0 {
+		maxPending = descriptor.MaxPending
+	}
+
+	var limiter *keyedRateLimiter
+	if descriptor.LimiterDescriptor != nil {
+		keyed, err := newStrategyKeyedRateLimiter(*descriptor.LimiterDescriptor)
+		if err != nil {
+			return nil, err
+		}
+		limiter = keyed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listener := &LimitedListener{
+		Listener:       inner,
+		limiter:        limiter,
+		maxConnections: descriptor.MaxConnections,
+		ipv4Prefix:     ipv4Prefix,
+		ipv6Prefix:     ipv6Prefix,
+		action:         action,
+		maxDelay:       maxDelay,
+		connections:    make(map[string]int),
+		pending:        make(chan struct{}, maxPending),
+		released:       make(chan struct{}),
+		ready:          make(chan net.Conn),
+		errs:           make(chan error),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	go listener.acceptLoop()
+	return listener, nil
+}
+
+func (l *LimitedListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.ready:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.ctx.Done():
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *LimitedListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.cancel()
+		err = l.Listener.Close()
+	})
+	return err
+}
+
+func (l *LimitedListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.ctx.Done():
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		source := l.sourceKey(conn.RemoteAddr())
+		allowed := l.allow(source)
+		if allowed && l.acquire(source) {
+			l.deliver(l.wrap(conn, source))
+			continue
+		}
+		if l.action == ListenerActionClose || !l.hold() {
+			conn.Close()
+			continue
+		}
+		go l.delay(conn, source, allowed)
+	}
+}
+
+// hold takes a pending slot for a delayed connection, if one is free.
+func (l *LimitedListener) hold() bool {
+	select {
+	case l.pending <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// delay holds a connection until its source is allowed again. allowed
+// reports whether the rate limiter already accepted it. The caller must have
+// taken a pending slot, which delay frees.
+func (l *LimitedListener) delay(conn net.Conn, source string, allowed bool) {
+	defer func() { <-l.pending }()
+	ctx, cancel := context.WithTimeout(l.ctx, l.maxDelay)
+	defer cancel()
+
+	err := waitAllowed(ctx, func() (RequestPipelineResponse, bool) {
+		if allowed || l.limiter == nil {
+			return RequestPipelineResponse{}, false
+		}
+		return l.limiter.evalKey(source, 1), true
+	})
+	if err == nil {
+		err = l.waitAcquire(ctx, source)
+	}
+	if err != nil {
+		conn.Close()
+		return
+	}
+	l.deliver(l.wrap(conn, source))
+}
+
+func (l *LimitedListener) deliver(conn net.Conn) {
+	select {
+	case l.ready <- conn:
+	case <-l.ctx.Done():
+		conn.Close()
+	}
+}
+
+func (l *LimitedListener) allow(source string) bool {
+	if l.limiter == nil {
+		return true
+	}
+	resp := l.limiter.evalKey(source, 1)
+	return <-resp.Allowed()
+}
+
+// acquire takes a connection slot of source, if one is free.
+func (l *LimitedListener) acquire(source string) bool {
+	if l.maxConnections == 0 {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.connections[source] >= l.maxConnections {
+		return false
+	}
+	l.connections[source]++
+	return true
+}
+
+// waitAcquire waits for a connection slot of source to be released.
+func (l *LimitedListener) waitAcquire(ctx context.Context, source string) error {
+	for {
+		l.mutex.Lock()
+		released := l.released
+		l.mutex.Unlock()
+		if l.acquire(source) {
+			return nil
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *LimitedListener) release(source string) {
+	if l.maxConnections == 0 {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.connections[source]--
+	if l.connections[source] <= 0 {
+		delete(l.connections, source)
+	}
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *LimitedListener) wrap(conn net.Conn, source string) net.Conn {
+	return &limitedConn{
+		Conn:    conn,
+		release: func() { l.release(source) },
+	}
+}
+
+// sourceKey masks the remote IP to the configured prefix. Addresses that are
+// not IP addresses, such as Unix sockets, are used as is.
+func (l *LimitedListener) sourceKey(addr net.Addr) string {
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	ip := addrPort.Addr().Unmap()
+	bits := l.ipv6Prefix
+	if ip.Is4() {
+		bits = l.ipv4Prefix
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return ip.String()
+	}
+	return prefix.String()
+}
+
+type limitedConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
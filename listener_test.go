@@ -0,0 +1,224 @@
+package rate_limiter
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestLimitedListener(t *testing.T, descriptor ListenerDescriptor) (*LimitedListener, <-chan net.Conn) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewLimitedListener(inner, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	// Accepted connections are handed over one at a time
+	accepted := make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	return listener, accepted
+}
+
+func dialTestListener(t *testing.T, listener *LimitedListener) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func acceptWithin(accepted <-chan net.Conn, timeout time.Duration) (net.Conn, bool) {
+	select {
+	case conn := <-accepted:
+		return conn, conn != nil
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
+
+func expectClosedByServer(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		if netErr, ok := err.(net.Error); !ok || netErr.Timeout() {
+			t.Errorf("Expected the connection to be closed by the listener, got %v", err)
+		}
+	}
+}
+
+func TestLimitedListener_AcceptRate(t *testing.T) {
+	listener, accepted := newTestLimitedListener(t, ListenerDescriptor{
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyFixedWindow,
+			Params:       map[string]any{"capacity": 2, "reset_interval": 60.0},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		dialTestListener(t, listener)
+		if _, ok := acceptWithin(accepted, time.Second); !ok {
+			t.Fatalf("Expected connection %d to be accepted", i+1)
+		}
+	}
+
+	excess := dialTestListener(t, listener)
+	if _, ok := acceptWithin(accepted, 100*time.Millisecond); ok {
+		t.Error("Expected the connection over the rate not to be accepted")
+	}
+	expectClosedByServer(t, excess)
+}
+
+func TestLimitedListener_MaxConnections(t *testing.T) {
+	listener, accepted := newTestLimitedListener(t, ListenerDescriptor{MaxConnections: 1})
+
+	dialTestListener(t, listener)
+	first, ok := acceptWithin(accepted, time.Second)
+	if !ok {
+		t.Fatal("Expected the first connection to be accepted")
+	}
+
+	excess := dialTestListener(t, listener)
+	if _, ok := acceptWithin(accepted, 100*time.Millisecond); ok {
+		t.Error("Expected a second concurrent connection not to be accepted")
+	}
+	expectClosedByServer(t, excess)
+
+	// Closing a connection frees its slot
+	first.Close()
+	dialTestListener(t, listener)
+	if _, ok := acceptWithin(accepted, time.Second); !ok {
+		t.Error("Expected a connection to be accepted after the first one closed")
+	}
+}
+
+func TestLimitedListener_DelayAction(t *testing.T) {
+	listener, accepted := newTestLimitedListener(t, ListenerDescriptor{
+		MaxConnections: 1,
+		Action:         ListenerActionDelay,
+		MaxDelay:       2,
+	})
+
+	dialTestListener(t, listener)
+	first, ok := acceptWithin(accepted, time.Second)
+	if !ok {
+		t.Fatal("Expected the first connection to be accepted")
+	}
+
+	dialTestListener(t, listener)
+	if _, ok := acceptWithin(accepted, 100*time.Millisecond); ok {
+		t.Fatal("Expected the second connection to be delayed")
+	}
+
+	first.Close()
+	if _, ok := acceptWithin(accepted, time.Second); !ok {
+		t.Error("Expected the delayed connection to be accepted once a slot was freed")
+	}
+}
+
+func TestLimitedListener_MaxPending(t *testing.T) {
+	listener, accepted := newTestLimitedListener(t, ListenerDescriptor{
+		MaxConnections: 1,
+		Action:         ListenerActionDelay,
+		MaxDelay:       5,
+		MaxPending:     1,
+	})
+
+	dialTestListener(t, listener)
+	first, ok := acceptWithin(accepted, time.Second)
+	if !ok {
+		t.Fatal("Expected the first connection to be accepted")
+	}
+
+	dialTestListener(t, listener)
+	excess := dialTestListener(t, listener)
+	// The second connection takes the only pending slot, so the third is
+	// closed without waiting for the delay.
+	expectClosedByServer(t, excess)
+
+	first.Close()
+	if _, ok := acceptWithin(accepted, time.Second); !ok {
+		t.Error("Expected the pending connection to be accepted once a slot was freed")
+	}
+}
+
+func TestLimitedListener_DefaultIPv6Prefix(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewLimitedListener(inner, ListenerDescriptor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("2001:db8::1:2:3:4"), Port: 1234}
+	if key := listener.sourceKey(addr); key != "2001:db8::/64" {
+		t.Errorf("Expected IPv6 sources to be keyed by /64 by default, got %s", key)
+	}
+}
+
+func TestLimitedListener_SourceKey(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewLimitedListener(inner, ListenerDescriptor{IPv4Prefix: 24, IPv6Prefix: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	tests := []struct {
+		addr     net.Addr
+		expected string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 1234}, "10.0.0.0/24"},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.0.1.7"), Port: 1234}, "10.0.1.0/24"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234}, "2001:db8::/64"},
+		{&net.UnixAddr{Name: "/tmp/socket", Net: "unix"}, "/tmp/socket"},
+	}
+	for _, tt := range tests {
+		if key := listener.sourceKey(tt.addr); key != tt.expected {
+			t.Errorf("Expected %s to be keyed as %s, got %s", tt.addr, tt.expected, key)
+		}
+	}
+}
+
+func TestNewLimitedListener_InvalidDescriptor(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	descriptors := []ListenerDescriptor{
+		{IPv4Prefix: 33},
+		{MaxConnections: -1},
+		{MaxPending: -1},
+		{Action: "drop"},
+		{LimiterDescriptor: &StrategyDescriptor{StrategyName: "unknown"}},
+	}
+	for _, descriptor := range descriptors {
+		if _, err := NewLimitedListener(inner, descriptor); err == nil {
+			t.Errorf("Expected an error for %+v", descriptor)
+		}
+	}
+}
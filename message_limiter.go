@@ -0,0 +1,217 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type MessagePolicy string
+
+const (
+	// MessagePolicyDrop discards messages over the limit.
+	MessagePolicyDrop MessagePolicy = "drop"
+	// MessagePolicyDelay holds messages over the limit until they are
+	// allowed, and paces every message through the traffic shaper if one is
+	// configured.
+	MessagePolicyDelay MessagePolicy = "delay"
+	// MessagePolicyClose ends the connection on the first message over the
+	// limit.
+	MessagePolicyClose MessagePolicy = "close"
+)
+
+// StatusPolicyViolation is the WebSocket close code sent by ServeMessages
+// when a connection is closed for exceeding its limit.
+const StatusPolicyViolation = 1008
+
+var ErrMessageLimitExceeded = errors.New("message limit exceeded")
+
+// MessageLimiterDescriptor limits the messages of long-lived connections,
+// such as WebSockets, after the initial request. The connection limiter
+// applies to each connection on its own, the user limiter to every
+// connection of a user together.
+type MessageLimiterDescriptor struct {
+	ConnectionLimiterDescriptor *StrategyDescriptor `json:"connection,omitempty" yaml:"connection,omitempty"`
+	UserLimiterDescriptor       *StrategyDescriptor `json:"user,omitempty" yaml:"user,omitempty"`
+	TrafficShaperDescriptor     *StrategyDescriptor `json:"traffic,omitempty" yaml:"traffic,omitempty"`
+	Policy                      MessagePolicy       `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// MessageLimiter hands out a MessageStream per connection. It is safe for
+// concurrent use.
+type MessageLimiter struct {
+	descriptor MessageLimiterDescriptor
+	policy     MessagePolicy
+	users      *keyedRateLimiter
+}
+
+// MessageStream limits the messages of one connection. It must be closed
+// when the connection ends.
+type MessageStream struct {
+	limiter     *MessageLimiter
+	user        string
+	connection  iRateLimiter
+	shaper      iTrafficShapeAlgorithm
+	closeSignal chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewMessageLimiter(descriptor MessageLimiterDescriptor) (*MessageLimiter, error) {
+	policy := descriptor.Policy
+	switch policy {
+	case "":
+		policy = MessagePolicyDrop
+	case MessagePolicyDrop, MessagePolicyDelay, MessagePolicyClose:
+	default:
+		return nil, fmt.Errorf("unknown message policy: %s", policy)
+	}
+
+	if descriptor.ConnectionLimiterDescriptor != nil {
+		if _, err := createRateLimiterFromDescriptor(*descriptor.ConnectionLimiterDescriptor); err != nil {
+			return nil, err
+		}
+	}
+	if descriptor.TrafficShaperDescriptor != nil {
+		if policy != MessagePolicyDelay {
+			return nil, fmt.Errorf("traffic shaper requires the %s policy", MessagePolicyDelay)
+		}
+		closeSignal := make(chan struct{})
+		_, err := createTrafficShaperFromDescriptor(*descriptor.TrafficShaperDescriptor, closeSignal)
+		close(closeSignal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var users *keyedRateLimiter
+	if descriptor.UserLimiterDescriptor != nil {
+		keyed, err := newStrategyKeyedRateLimiter(*descriptor.UserLimiterDescriptor)
+		if err != nil {
+			return nil, err
+		}
+		users = keyed
+	}
+
+	return &MessageLimiter{
+		descriptor: descriptor,
+		policy:     policy,
+		users:      users,
+	}, nil
+}
+
+// NewStream starts limiting a connection of user. An empty user only applies
+// the connection limiter.
+func (m *MessageLimiter) NewStream(user string) *MessageStream {
+	stream := &MessageStream{
+		limiter:     m,
+		user:        user,
+		closeSignal: make(chan struct{}),
+	}
+	if m.descriptor.ConnectionLimiterDescriptor != nil {
+		stream.connection, _ = createRateLimiterFromDescriptor(*m.descriptor.ConnectionLimiterDescriptor)
+	}
+	if m.descriptor.TrafficShaperDescriptor != nil {
+		stream.shaper, _ = createTrafficShaperFromDescriptor(*m.descriptor.TrafficShaperDescriptor, stream.closeSignal)
+	}
+	return stream
+}
+
+// Take evaluates one message. It reports false for a message to drop, and
+// returns ErrMessageLimitExceeded when the connection must be closed. Under
+// MessagePolicyDelay it waits until the message is allowed, or returns the
+// context error if ctx is done first.
+func (s *MessageStream) Take(ctx context.Context) (bool, error) {
+	if s.limiter.policy == MessagePolicyDelay {
+		return true, s.wait(ctx)
+	}
+
+	if s.allow(s.evalConnection) && s.allow(s.evalUser) {
+		return true, nil
+	}
+	if s.limiter.policy == MessagePolicyClose {
+		return false, ErrMessageLimitExceeded
+	}
+	return false, nil
+}
+
+func (s *MessageStream) Close() {
+	s.closeOnce.Do(func() { close(s.closeSignal) })
+}
+
+func (s *MessageStream) wait(ctx context.Context) error {
+	if err := waitAllowed(ctx, s.evalConnection); err != nil {
+		return err
+	}
+	if err := waitAllowed(ctx, s.evalUser); err != nil {
+		return err
+	}
+	if s.shaper == nil {
+		return nil
+	}
+	select {
+	case <-s.shaper.addRequest():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *MessageStream) allow(evaluate func() (RequestPipelineResponse, bool)) bool {
+	resp, limited := evaluate()
+	return !limited || <-resp.Allowed()
+}
+
+func (s *MessageStream) evalConnection() (RequestPipelineResponse, bool) {
+	if s.connection == nil {
+		return RequestPipelineResponse{}, false
+	}
+	return s.connection.eval(), true
+}
+
+func (s *MessageStream) evalUser() (RequestPipelineResponse, bool) {
+	if s.limiter.users == nil || s.user == "" {
+		return RequestPipelineResponse{}, false
+	}
+	return s.limiter.users.evalKey(s.user, 1), true
+}
+
+// ServeMessages runs a message loop in the style of golang.org/x/net/websocket:
+// receive reads the next message, for example
+//
+//	func(msg *string) error { return websocket.Message.Receive(ws, msg) }
+//
+// and handle processes the allowed ones. Messages are dropped or delayed as
+// the policy says. When the limit closes the connection, conn is sent a
+// StatusPolicyViolation close frame if it has a WriteClose(status int) method,
+// like *websocket.Conn, then closed, and ErrMessageLimitExceeded is returned.
+// The loop otherwise ends with the first error of receive or handle. The
+// stream is closed when the loop ends.
+func ServeMessages[T any](ctx context.Context, conn io.Closer, stream *MessageStream, receive func(*T) error, handle func(T) error) error {
+	defer stream.Close()
+	for {
+		var message T
+		if err := receive(&message); err != nil {
+			return err
+		}
+
+		allowed, err := stream.Take(ctx)
+		if errors.Is(err, ErrMessageLimitExceeded) {
+			if closer, ok := conn.(interface{ WriteClose(status int) error }); ok {
+				closer.WriteClose(StatusPolicyViolation)
+			}
+			conn.Close()
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			continue
+		}
+		if err := handle(message); err != nil {
+			return err
+		}
+	}
+}
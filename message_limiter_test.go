@@ -0,0 +1,234 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func newTestFixedWindow(capacity int) *StrategyDescriptor {
+	return &StrategyDescriptor{
+		StrategyName: LimiterStrategyFixedWindow,
+		Params:       map[string]any{"capacity": capacity, "reset_interval": 60.0},
+	}
+}
+
+func TestMessageStream_DropPolicy(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{ConnectionLimiterDescriptor: newTestFixedWindow(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := limiter.NewStream("")
+	defer stream.Close()
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := stream.Take(context.Background()); !allowed || err != nil {
+			t.Fatalf("Expected message %d to be allowed, got %v, %v", i+1, allowed, err)
+		}
+	}
+	if allowed, err := stream.Take(context.Background()); allowed || err != nil {
+		t.Errorf("Expected the third message to be dropped, got %v, %v", allowed, err)
+	}
+
+	// Every connection has its own bucket
+	other := limiter.NewStream("")
+	defer other.Close()
+	if allowed, _ := other.Take(context.Background()); !allowed {
+		t.Error("Expected another connection to be allowed")
+	}
+}
+
+func TestMessageStream_UserLimitSharedByConnections(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{UserLimiterDescriptor: newTestFixedWindow(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := limiter.NewStream("alice")
+	second := limiter.NewStream("alice")
+	other := limiter.NewStream("bob")
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		for _, stream := range []*MessageStream{first, second} {
+			if ok, _ := stream.Take(context.Background()); ok {
+				allowed++
+			}
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Expected the user limit to be shared by both connections, got %d allowed", allowed)
+	}
+	if ok, _ := other.Take(context.Background()); !ok {
+		t.Error("Expected another user to have their own bucket")
+	}
+}
+
+func TestMessageStream_ClosePolicy(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{
+		ConnectionLimiterDescriptor: newTestFixedWindow(1),
+		Policy:                      MessagePolicyClose,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := limiter.NewStream("")
+	defer stream.Close()
+
+	stream.Take(context.Background())
+	if _, err := stream.Take(context.Background()); !errors.Is(err, ErrMessageLimitExceeded) {
+		t.Errorf("Expected ErrMessageLimitExceeded, got %v", err)
+	}
+}
+
+func TestMessageStream_DelayPolicy(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{
+		ConnectionLimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyTokenBucket,
+			Params:       map[string]any{"capacity": 1, "refill_rate": 20, "request_cost": 1},
+		},
+		Policy: MessagePolicyDelay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := limiter.NewStream("")
+	defer stream.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if allowed, err := stream.Take(context.Background()); !allowed || err != nil {
+			t.Fatalf("Expected message %d to be delayed then allowed, got %v, %v", i+1, allowed, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected messages to be spaced by the refill rate, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	stream.Take(context.Background())
+	if _, err := stream.Take(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delay to end with the context, got %v", err)
+	}
+}
+
+func TestMessageStream_DelayThroughShaper(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{
+		TrafficShaperDescriptor: &StrategyDescriptor{
+			StrategyName: TrafficStrategyLeakyBucket,
+			Params:       map[string]any{"capacity": 10, "drop_per_second": 20},
+		},
+		Policy: MessagePolicyDelay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := limiter.NewStream("")
+	defer stream.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := stream.Take(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected messages to be released at the drop rate, took %v", elapsed)
+	}
+}
+
+type fakeMessageConn struct {
+	closeStatus int
+	closed      bool
+}
+
+func (f *fakeMessageConn) WriteClose(status int) error {
+	f.closeStatus = status
+	return nil
+}
+
+func (f *fakeMessageConn) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newTestMessageReceiver(messages []string) func(*string) error {
+	return func(message *string) error {
+		if len(messages) == 0 {
+			return io.EOF
+		}
+		*message, messages = messages[0], messages[1:]
+		return nil
+	}
+}
+
+func TestServeMessages(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{ConnectionLimiterDescriptor: newTestFixedWindow(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []string
+	conn := &fakeMessageConn{}
+	err = ServeMessages(context.Background(), conn, limiter.NewStream("alice"), newTestMessageReceiver([]string{"a", "b", "c", "d"}), func(message string) error {
+		handled = append(handled, message)
+		return nil
+	})
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Expected the loop to end with the receive error, got %v", err)
+	}
+	if len(handled) != 2 || handled[0] != "a" || handled[1] != "b" {
+		t.Errorf("Expected messages over the limit to be dropped, handled %v", handled)
+	}
+	if conn.closed {
+		t.Error("Expected the connection not to be closed by the drop policy")
+	}
+}
+
+func TestServeMessages_ClosePolicy(t *testing.T) {
+	limiter, err := NewMessageLimiter(MessageLimiterDescriptor{
+		ConnectionLimiterDescriptor: newTestFixedWindow(2),
+		Policy:                      MessagePolicyClose,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := 0
+	conn := &fakeMessageConn{}
+	err = ServeMessages(context.Background(), conn, limiter.NewStream(""), newTestMessageReceiver([]string{"a", "b", "c", "d"}), func(message string) error {
+		handled++
+		return nil
+	})
+	if !errors.Is(err, ErrMessageLimitExceeded) {
+		t.Errorf("Expected ErrMessageLimitExceeded, got %v", err)
+	}
+	if handled != 2 {
+		t.Errorf("Expected 2 messages to be handled, got %d", handled)
+	}
+	if !conn.closed || conn.closeStatus != StatusPolicyViolation {
+		t.Errorf("Expected the connection to be closed with status %d, got %v %d", StatusPolicyViolation, conn.closed, conn.closeStatus)
+	}
+}
+
+func TestNewMessageLimiter_InvalidDescriptor(t *testing.T) {
+	descriptors := []MessageLimiterDescriptor{
+		{Policy: "ignore"},
+		{ConnectionLimiterDescriptor: &StrategyDescriptor{StrategyName: "unknown"}},
+		{UserLimiterDescriptor: &StrategyDescriptor{StrategyName: "unknown"}},
+		{TrafficShaperDescriptor: &StrategyDescriptor{
+			StrategyName: TrafficStrategyLeakyBucket,
+			Params:       map[string]any{"capacity": 10, "drop_per_second": 20},
+		}},
+	}
+	for _, descriptor := range descriptors {
+		if _, err := NewMessageLimiter(descriptor); err == nil {
+			t.Errorf("Expected an error for %+v", descriptor)
+		}
+	}
+}
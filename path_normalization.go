@@ -0,0 +1,124 @@
+package rate_limiter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PathNormalization configures how paths are normalized before matching, so
+// that variants of a path such as /api//users, /API/users or
+// /api/admin/../users cannot bypass its limits. It applies to the paths of
+// routes and of requests alike. The zero value matches paths as they are.
+type PathNormalization struct {
+	// CleanDotSegments resolves "." and ".." segments, never above the root.
+	CleanDotSegments bool `json:"clean_dot_segments,omitempty" yaml:"clean_dot_segments,omitempty"`
+	// CollapseSlashes drops empty segments, so // matches like /.
+	CollapseSlashes bool `json:"collapse_slashes,omitempty" yaml:"collapse_slashes,omitempty"`
+	// CaseInsensitive matches static segments regardless of case. Variables
+	// capture the segment as requested.
+	CaseInsensitive bool `json:"case_insensitive,omitempty" yaml:"case_insensitive,omitempty"`
+	// DecodePercent decodes percent-encoded characters, including %2F which
+	// then separates segments. Paths taken from http.Request.URL.Path are
+	// already decoded, and decoding them again would decode %25 twice.
+	DecodePercent bool `json:"decode_percent,omitempty" yaml:"decode_percent,omitempty"`
+	// StripQuery ignores the query and fragment of request paths.
+	StripQuery bool `json:"strip_query,omitempty" yaml:"strip_query,omitempty"`
+}
+
+// ParsePathNormalization parses a comma-separated list of the options of a
+// PathNormalization, named as in configuration files, such as
+// "clean_dot_segments,collapse_slashes". An empty list normalizes nothing.
+func ParsePathNormalization(value string) (PathNormalization, error) {
+	var normalization PathNormalization
+	options := map[string]*bool{
+		"clean_dot_segments": &normalization.CleanDotSegments,
+		"collapse_slashes":   &normalization.CollapseSlashes,
+		"case_insensitive":   &normalization.CaseInsensitive,
+		"decode_percent":     &normalization.DecodePercent,
+		"strip_query":        &normalization.StripQuery,
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		option, exists := options[name]
+		if !exists {
+			return PathNormalization{}, fmt.Errorf("unknown path normalization option: %s", name)
+		}
+		*option = true
+	}
+	return normalization, nil
+}
+
+// requestParts returns the segments of a request path, the keys its static
+// segments are looked up by, and whether the path ends with a slash.
+func (n PathNormalization) requestParts(path string) ([]string, []string, bool) {
+	if n.StripQuery {
+		if end := strings.IndexAny(path, "?#"); end >= 0 {
+			path = path[:end]
+		}
+	}
+	if n.DecodePercent {
+		if decoded, err := url.PathUnescape(path); err == nil {
+			path = decoded
+		}
+	}
+	parts := n.cleanParts(strings.Split(strings.Trim(path, "/"), "/"))
+	// The root path has a single empty segment and no trailing slash
+	trailingSlash := strings.HasSuffix(path, "/") && (len(parts) > 1 || parts[0] != "")
+	if !n.CaseInsensitive {
+		return parts, parts, trailingSlash
+	}
+	keys := make([]string, len(parts))
+	for i, part := range parts {
+		keys[i] = strings.ToLower(part)
+	}
+	return parts, keys, trailingSlash
+}
+
+// routePath normalizes the static segments of a route path like requestParts
+// normalizes the segments of requests, leaving variables untouched.
+func (n PathNormalization) routePath(path string) string {
+	parts := splitRoutePath(path)
+	for i, part := range parts {
+		if kind, _, _ := parsePathSegment(part); kind != segmentStatic {
+			continue
+		}
+		if n.DecodePercent {
+			if decoded, err := url.PathUnescape(part); err == nil {
+				part = decoded
+			}
+		}
+		if n.CaseInsensitive {
+			part = strings.ToLower(part)
+		}
+		parts[i] = part
+	}
+	return "/" + strings.Join(n.cleanParts(strings.Split(strings.Join(parts, "/"), "/")), "/")
+}
+
+func (n PathNormalization) cleanParts(parts []string) []string {
+	if !n.CollapseSlashes && !n.CleanDotSegments {
+		return parts
+	}
+	cleaned := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case n.CollapseSlashes && part == "":
+		case n.CleanDotSegments && part == ".":
+		case n.CleanDotSegments && part == "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+		default:
+			cleaned = append(cleaned, part)
+		}
+	}
+	if len(cleaned) == 0 {
+		// The root path has a single empty segment
+		cleaned = append(cleaned, "")
+	}
+	return cleaned
+}
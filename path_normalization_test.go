@@ -0,0 +1,182 @@
+package rate_limiter
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestPathNormalization_RequestParts(t *testing.T) {
+	all := PathNormalization{
+		CleanDotSegments: true,
+		CollapseSlashes:  true,
+		CaseInsensitive:  true,
+		DecodePercent:    true,
+		StripQuery:       true,
+	}
+	tests := []struct {
+		normalization PathNormalization
+		path          string
+		parts         []string
+		keys          []string
+	}{
+		{PathNormalization{}, "/api//users?x=1", []string{"api", "", "users?x=1"}, nil},
+		{all, "/api//users?x=1#top", []string{"api", "users"}, nil},
+		{all, "/api/users/../admin/./logs", []string{"api", "admin", "logs"}, nil},
+		{all, "/../../api", []string{"api"}, nil},
+		{all, "/api/%41dmin%2Flogs", []string{"api", "Admin", "logs"}, []string{"api", "admin", "logs"}},
+		{all, "/api/%zz", []string{"api", "%zz"}, nil},
+		{all, "//", []string{""}, nil},
+		{PathNormalization{CleanDotSegments: true}, "/api//../users", []string{"api", "users"}, nil},
+	}
+	for _, tt := range tests {
+		parts, keys, _ := tt.normalization.requestParts(tt.path)
+		if tt.keys == nil {
+			tt.keys = tt.parts
+		}
+		if !slices.Equal(parts, tt.parts) || !slices.Equal(keys, tt.keys) {
+			t.Errorf("Expected %s to normalize to %q and keys %q, got %q and %q", tt.path, tt.parts, tt.keys, parts, keys)
+		}
+	}
+}
+
+func TestPathNormalization_RoutePath(t *testing.T) {
+	normalization := PathNormalization{CleanDotSegments: true, CollapseSlashes: true, CaseInsensitive: true, DecodePercent: true}
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/API//Users/:ID<[A-Z]+>", "/api/users/:ID<[A-Z]+>"},
+		{"/Files/{Rest...}", "/files/{Rest...}"},
+		{"/a/./b/../%43", "/a/c"},
+		{"/{$}", "/{$}"},
+		{"/Static/", "/static"},
+		{"/", "/"},
+	}
+	for _, tt := range tests {
+		if path := normalization.routePath(tt.path); path != tt.expected {
+			t.Errorf("Expected %s to normalize to %s, got %s", tt.path, tt.expected, path)
+		}
+	}
+}
+
+func TestParsePathNormalization(t *testing.T) {
+	normalization, err := ParsePathNormalization("clean_dot_segments, collapse_slashes,strip_query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PathNormalization{CleanDotSegments: true, CollapseSlashes: true, StripQuery: true}
+	if normalization != expected {
+		t.Errorf("Expected %+v, got %+v", expected, normalization)
+	}
+	if normalization, err := ParsePathNormalization(""); err != nil || normalization != (PathNormalization{}) {
+		t.Errorf("Expected an empty list to normalize nothing, got %+v, %v", normalization, err)
+	}
+	if _, err := ParsePathNormalization("lowercase"); err == nil {
+		t.Error("Expected an error for an unknown option")
+	}
+}
+
+func TestRouterBuilder_LoadNormalization(t *testing.T) {
+	expected := PathNormalization{CollapseSlashes: true, CaseInsensitive: true}
+	configs := map[string]func(*RouterBuilder) error{
+		"yaml": func(builder *RouterBuilder) error {
+			return builder.LoadFromYaml([]byte(`
+normalization:
+  collapse_slashes: true
+  case_insensitive: true
+routes:
+  - path: /api/users
+    limiter: {type: fixed_window, params: {capacity: 1, reset_interval: 60}}
+`))
+		},
+		"json": func(builder *RouterBuilder) error {
+			return builder.LoadFromJson([]byte(`{
+				"normalization": {"collapse_slashes": true, "case_insensitive": true},
+				"routes": [{"path": "/api/users", "limiter": {"type": "fixed_window", "params": {"capacity": 1, "reset_interval": 60}}}]
+			}`))
+		},
+	}
+	for name, load := range configs {
+		t.Run(name, func(t *testing.T) {
+			builder := NewRouterBuilder(make(chan struct{}))
+			if err := load(&builder); err != nil {
+				t.Fatal(err)
+			}
+			if builder.normalization != expected {
+				t.Errorf("Expected the normalization %+v, got %+v", expected, builder.normalization)
+			}
+			router := mustBuildRouter(t, &builder)
+			if _, found := router.MatchRoute(RouteRequest{Path: "/API//users"}); !found {
+				t.Error("Expected the normalized path to match")
+			}
+
+			// Exports keep the normalization
+			data, err := builder.ExportToYaml()
+			if err != nil {
+				t.Fatal(err)
+			}
+			reloaded := NewRouterBuilder(make(chan struct{}))
+			if err := reloaded.LoadFromYaml(data); err != nil {
+				t.Fatal(err)
+			}
+			if reloaded.normalization != expected || len(reloaded.GetRouteDescriptors()) != 1 {
+				t.Errorf("Expected the export to round-trip, got %+v and %d routes", reloaded.normalization, len(reloaded.GetRouteDescriptors()))
+			}
+		})
+	}
+}
+
+func TestRouter_PathNormalization(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetPathNormalization(PathNormalization{
+		CleanDotSegments: true,
+		CollapseSlashes:  true,
+		CaseInsensitive:  true,
+		DecodePercent:    true,
+		StripQuery:       true,
+	})
+	builder.SetRoute(newTestFixedWindowRoute("/API/Admin/:name", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/**", nil, 10))
+	router := mustBuildRouter(t, &builder)
+
+	for _, path := range []string{
+		"/api/admin/Logs",
+		"/api//admin/Logs",
+		"/API/Admin/Logs?page=2",
+		"/api/users/../admin/Logs",
+		"/api/%61dmin/Logs",
+	} {
+		match, found := router.MatchRoute(RouteRequest{Method: http.MethodGet, Path: path})
+		if !found || match.Pattern != "/API/Admin/:name" {
+			t.Errorf("Expected %s to match the admin route, got %q", path, match.Pattern)
+			continue
+		}
+		if match.Params["name"] != "Logs" {
+			t.Errorf("Expected %s to capture the segment as requested, got %q", path, match.Params["name"])
+		}
+	}
+
+	// Without normalization, variants of the path escape the route
+	builder.SetPathNormalization(PathNormalization{})
+	router = mustBuildRouter(t, &builder)
+	if match, _ := router.MatchRoute(RouteRequest{Path: "/api//admin/Logs"}); match.Pattern != "/**" {
+		t.Errorf("Expected the raw path not to match the admin route, got %q", match.Pattern)
+	}
+}
+
+func TestRouterBuilder_AnalyzeNormalizedDuplicates(t *testing.T) {
+	builder := NewRouterBuilder(make(chan struct{}))
+	builder.SetRoute(newTestFixedWindowRoute("/Users", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/users", nil, 1))
+	if issues := builder.Analyze(); len(issues) != 0 {
+		t.Fatalf("Expected case-sensitive routes to be distinct, got %v", issues)
+	}
+	builder.SetPathNormalization(PathNormalization{CaseInsensitive: true})
+	if issues := builder.Analyze(); len(issues) != 1 || issues[0].Severity != RouteIssueError {
+		t.Errorf("Expected case-insensitive routes to be duplicates, got %v", issues)
+	}
+}
@@ -1,8 +1,19 @@
 package rate_limiter
 
+import (
+	"context"
+	"time"
+)
+
+// defaultWaitRetryInterval is how long waitAllowed sleeps before asking again
+// when a rejection does not say when the request could be allowed.
+const defaultWaitRetryInterval = 100 * time.Millisecond
+
 type requestPipeline struct {
-	rateLimiter   iRateLimiter
-	trafficShaper iTrafficShapeAlgorithm
+	rateLimiter      iRateLimiter
+	keyedRateLimiter *keyedRateLimiter
+	trafficShaper    iTrafficShapeAlgorithm
+	bandwidth        *keyedBandwidthLimiter
 }
 
 func newRequestPipeline(rateLimiter iRateLimiter, trafficShaper iTrafficShapeAlgorithm) requestPipeline {
@@ -12,14 +23,77 @@ func newRequestPipeline(rateLimiter iRateLimiter, trafficShaper iTrafficShapeAlg
 	}
 }
 
+func newKeyedRequestPipeline(rateLimiter iRateLimiter, keyedRateLimiter *keyedRateLimiter, trafficShaper iTrafficShapeAlgorithm) requestPipeline {
+	return requestPipeline{
+		rateLimiter:      rateLimiter,
+		keyedRateLimiter: keyedRateLimiter,
+		trafficShaper:    trafficShaper,
+	}
+}
+
 func (r *requestPipeline) handleRequest() RequestPipelineResponse {
-	limiter := r.rateLimiter.eval()
+	return r.handleKeyedRequest("", 1)
+}
+
+// handleKeyedRequest evaluates the request against the limiter of key, or
+// the route-wide limiter when key is empty. The traffic shaper is shared by
+// every key of the route.
+func (r *requestPipeline) handleKeyedRequest(key string, cost float64) RequestPipelineResponse {
+	limiter := newSyncRequestPipelineResponse(true)
+	if key != "" && r.keyedRateLimiter != nil {
+		limiter = r.keyedRateLimiter.evalKey(key, cost)
+	} else if r.rateLimiter != nil {
+		limiter = r.rateLimiter.evalCost(cost)
+	}
+
 	if !<-limiter.Allowed() {
-		return newSyncRequestPipelineResponse(false)
+		return limiter
 	}
 	if r.trafficShaper == nil {
 		return limiter
 	}
 	responseChan := r.trafficShaper.addRequest()
-	return newAsyncRequestPipelineResponse(responseChan)
+	return newAsyncRequestPipelineResponse(responseChan).withQuota(limiter.limit, limiter.remaining, limiter.resetAfter, limiter.retryAfter)
+}
+
+// waitAllowed evaluates a request until it is allowed, sleeping between
+// attempts for the delay reported by each rejection, or until ctx is done.
+// evaluate reports false when no limit applies.
+func waitAllowed(ctx context.Context, evaluate func() (RequestPipelineResponse, bool)) error {
+	for {
+		resp, matched := evaluate()
+		if !matched {
+			return nil
+		}
+
+		select {
+		case allowed := <-resp.Allowed():
+			if allowed {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		delay := resp.RetryAfter()
+		if delay <= 0 {
+			delay = defaultWaitRetryInterval
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, or returns the context error if ctx is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,9 +1,17 @@
 package rate_limiter
 
+import "time"
+
 type RequestPipelineResponse struct {
 	allowed           bool
 	asyncResponse     bool
 	asyncResponseChan <-chan bool
+	limit             float64
+	remaining         float64
+	resetAfter        time.Duration
+	retryAfter        time.Duration
+	pattern           string
+	params            map[string]string
 }
 
 func newSyncRequestPipelineResponse(allowed bool) RequestPipelineResponse {
@@ -20,6 +28,22 @@ func newAsyncRequestPipelineResponse(asyncResponseChan <-chan bool) RequestPipel
 	}
 }
 
+// withQuota attaches the state of the limiter that took the decision.
+func (r RequestPipelineResponse) withQuota(limit, remaining float64, resetAfter, retryAfter time.Duration) RequestPipelineResponse {
+	r.limit = limit
+	r.remaining = max(remaining, 0)
+	r.resetAfter = max(resetAfter, 0)
+	r.retryAfter = max(retryAfter, 0)
+	return r
+}
+
+// withRoute attaches the route that matched the request.
+func (r RequestPipelineResponse) withRoute(pattern string, params map[string]string) RequestPipelineResponse {
+	r.pattern = pattern
+	r.params = params
+	return r
+}
+
 func (r *RequestPipelineResponse) Allowed() <-chan bool {
 	if r.asyncResponse {
 		return r.asyncResponseChan
@@ -35,3 +59,37 @@ func (r *RequestPipelineResponse) Allowed() <-chan bool {
 func (r *RequestPipelineResponse) IsAsync() bool {
 	return r.asyncResponse
 }
+
+// Limit returns the capacity of the limiter that took the decision, or 0 if
+// it does not report one (for example, shared backends).
+func (r *RequestPipelineResponse) Limit() float64 {
+	return r.limit
+}
+
+// Remaining returns the capacity left after the decision.
+func (r *RequestPipelineResponse) Remaining() float64 {
+	return r.remaining
+}
+
+// ResetAfter returns how long until the limiter is back to full capacity.
+func (r *RequestPipelineResponse) ResetAfter() time.Duration {
+	return r.resetAfter
+}
+
+// RetryAfter returns how long a rejected request should wait before the same
+// request could be allowed. It is 0 for allowed requests.
+func (r *RequestPipelineResponse) RetryAfter() time.Duration {
+	return r.retryAfter
+}
+
+// Pattern returns the path of the matched route as configured, such as
+// /api/:tenant/*, or "" when no route matched.
+func (r *RequestPipelineResponse) Pattern() string {
+	return r.pattern
+}
+
+// Params returns the values of the named path variables and catch-all of the
+// matched route.
+func (r *RequestPipelineResponse) Params() map[string]string {
+	return r.params
+}
@@ -0,0 +1,95 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	respMaxArguments  = 64
+	respMaxBulkLength = 64 * 1024
+	// respMaxLineLength bounds inline commands and the lines of RESP headers.
+	respMaxLineLength = respMaxBulkLength + 2
+)
+
+var errRESPProtocol = errors.New("protocol error")
+
+// readRESPCommand reads one command, either as a RESP array of bulk strings,
+// as sent by client libraries, or as an inline line of space separated words,
+// as typed in telnet or redis-cli.
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > respMaxArguments {
+		return nil, errRESPProtocol
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readRESPLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, errRESPProtocol
+		}
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > respMaxBulkLength {
+			return nil, errRESPProtocol
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		if string(data[length:]) != "\r\n" {
+			return nil, errRESPProtocol
+		}
+		args = append(args, string(data[:length]))
+	}
+	return args, nil
+}
+
+// readRESPLine reads a line of up to respMaxLineLength bytes, so a client
+// that never sends a newline cannot make the server buffer without bound.
+func readRESPLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > respMaxLineLength {
+			return "", errRESPProtocol
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func writeRESPSimpleString(writer *bufio.Writer, value string) {
+	fmt.Fprintf(writer, "+%s\r\n", value)
+}
+
+func writeRESPError(writer *bufio.Writer, message string) {
+	fmt.Fprintf(writer, "-ERR %s\r\n", message)
+}
+
+func writeRESPIntegers(writer *bufio.Writer, values ...int64) {
+	fmt.Fprintf(writer, "*%d\r\n", len(values))
+	for _, value := range values {
+		fmt.Fprintf(writer, ":%d\r\n", value)
+	}
+}
@@ -0,0 +1,46 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadRESPCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"*3\r\n$11\r\nCL.THROTTLE\r\n$4\r\nuser\r\n$2\r\n15\r\n", []string{"CL.THROTTLE", "user", "15"}},
+		{"*1\r\n$0\r\n\r\n", []string{""}},
+		{"PING\r\n", []string{"PING"}},
+		{"rl.check /api  client\n", []string{"rl.check", "/api", "client"}},
+	}
+	for _, test := range tests {
+		args, err := readRESPCommand(bufio.NewReader(strings.NewReader(test.input)))
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		if strings.Join(args, "|") != strings.Join(test.expected, "|") {
+			t.Errorf("Expected %q, got %q", test.expected, args)
+		}
+	}
+}
+
+func TestReadRESPCommand_ProtocolErrors(t *testing.T) {
+	inputs := []string{
+		"*x\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$3\r\nabcd\r\n",
+		"*1\r\n$-5\r\n",
+		"*1000\r\n",
+		strings.Repeat("a", respMaxLineLength+1) + "\r\n",
+		"*1\r\n$" + strings.Repeat("1", respMaxLineLength) + "\r\n",
+	}
+	for _, input := range inputs {
+		if _, err := readRESPCommand(bufio.NewReader(strings.NewReader(input))); !errors.Is(err, errRESPProtocol) {
+			t.Errorf("Expected a protocol error for %q, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRESPReadTimeout closes connections that stay idle, or take as
+	// long to send a command.
+	defaultRESPReadTimeout = 5 * time.Minute
+)
+
+type respThrottleParams struct {
+	maxBurst int64
+	count    int64
+	period   int64
+}
+
+// respThrottleKey prefixes a CL.THROTTLE key with its parameters, so every
+// throttle shares one bounded keyed limiter whatever parameters clients pick.
+func respThrottleKey(params respThrottleParams, key string) string {
+	return fmt.Sprintf("%d %d %d %s", params.maxBurst, params.count, params.period, key)
+}
+
+func parseRESPThrottleKey(key string) respThrottleParams {
+	var params respThrottleParams
+	fmt.Sscanf(key, "%d %d %d", &params.maxBurst, &params.count, &params.period)
+	return params
+}
+
+func (p respThrottleParams) capacity() float64 {
+	return float64(p.maxBurst + 1)
+}
+
+func (p respThrottleParams) refillRate() float64 {
+	return float64(p.count) / float64(p.period)
+}
+
+// RESPServer exposes limits over the Redis protocol so applications can use
+// stock Redis clients. It understands:
+//
+//	CL.THROTTLE key max_burst count period [quantity]
+//	RL.CHECK route key [cost]
+//	PING
+//
+// CL.THROTTLE follows redis-cell: key may perform count actions per period
+// seconds with bursts of up to max_burst+1, and the reply is
+// [limited, limit, remaining, retry_after, reset_after] with durations in
+// seconds and retry_after set to -1 when allowed, or when the quantity exceeds
+// the burst and can never be allowed. RL.CHECK evaluates a route of the router
+// for key and replies in the same format; route is a path, optionally preceded
+// by a method as in "POST /orders".
+//
+// A connection is closed when a command takes longer than ReadTimeout to
+// arrive, including the wait for it while the connection is idle; zero
+// disables the timeout.
+type RESPServer struct {
+	ReadTimeout time.Duration
+
+	router    Router
+	throttles *keyedRateLimiter
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	mutex     sync.Mutex
+	wg        sync.WaitGroup
+}
+
+func NewRESPServer(router Router) *RESPServer {
+	throttles := newKeyedRateLimiter(func(key string) iRateLimiter {
+		params := parseRESPThrottleKey(key)
+		return newTokenBucketRateLimiter(params.capacity(), params.refillRate(), 1)
+	}, 0)
+	throttles.keyIdleTTL = func(key string) time.Duration {
+		params := parseRESPThrottleKey(key)
+		return time.Duration(params.capacity() / params.refillRate() * float64(time.Second))
+	}
+	return &RESPServer{
+		ReadTimeout: defaultRESPReadTimeout,
+		router:      router,
+		throttles:   throttles,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+		mutex:       sync.Mutex{},
+	}
+}
+
+// Serve accepts connections on listener until it fails or Close is called.
+func (s *RESPServer) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return net.ErrClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return err
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops every listener and connection and waits for them to finish.
+func (s *RESPServer) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *RESPServer) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		s.wg.Done()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		}
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			if errors.Is(err, errRESPProtocol) {
+				writeRESPError(writer, err.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		if strings.ToUpper(args[0]) == "QUIT" {
+			writeRESPSimpleString(writer, "OK")
+			writer.Flush()
+			return
+		}
+		s.handleCommand(writer, args)
+		// Pipelined commands are answered together.
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *RESPServer) handleCommand(writer *bufio.Writer, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		writeRESPSimpleString(writer, "PONG")
+	case "CL.THROTTLE":
+		s.handleThrottle(writer, args[1:])
+	case "RL.CHECK":
+		s.handleCheck(writer, args[1:])
+	default:
+		writeRESPError(writer, "unknown command '"+args[0]+"'")
+	}
+}
+
+func (s *RESPServer) handleThrottle(writer *bufio.Writer, args []string) {
+	if len(args) != 4 && len(args) != 5 {
+		writeRESPError(writer, "wrong number of arguments for 'cl.throttle' command")
+		return
+	}
+	values := make([]int64, 0, 4)
+	for _, arg := range args[1:] {
+		value, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || value < 0 {
+			writeRESPError(writer, "value is not a non-negative integer")
+			return
+		}
+		values = append(values, value)
+	}
+	params := respThrottleParams{maxBurst: values[0], count: values[1], period: values[2]}
+	quantity := int64(1)
+	if len(values) == 4 {
+		quantity = values[3]
+	}
+	if params.count == 0 || params.period == 0 {
+		writeRESPError(writer, "count and period must be positive")
+		return
+	}
+
+	resp := s.throttles.evalKey(respThrottleKey(params, args[0]), float64(quantity))
+	// Like redis-cell, a quantity above the burst is refused without a retry
+	// time, as waiting would never allow it.
+	if float64(quantity) > params.capacity() {
+		<-resp.Allowed()
+		writeRESPIntegers(writer, 1, int64(resp.Limit()), int64(math.Floor(resp.Remaining())), -1, respSeconds(resp.ResetAfter()))
+		return
+	}
+	writeRESPDecision(writer, resp)
+}
+
+func (s *RESPServer) handleCheck(writer *bufio.Writer, args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		writeRESPError(writer, "wrong number of arguments for 'rl.check' command")
+		return
+	}
+	cost := 1.0
+	if len(args) == 3 {
+		value, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || value < 0 {
+			writeRESPError(writer, "cost is not a non-negative number")
+			return
+		}
+		cost = value
+	}
+
+	method, path := splitRESPRoute(args[0])
+	resp, _ := s.router.HandleKeyedRequest(method, path, args[1], cost)
+	writeRESPDecision(writer, resp)
+}
+
+func writeRESPDecision(writer *bufio.Writer, resp RequestPipelineResponse) {
+	limited := int64(0)
+	retryAfter := int64(-1)
+	if !<-resp.Allowed() {
+		limited = 1
+		retryAfter = respSeconds(resp.RetryAfter())
+	}
+	writeRESPIntegers(writer,
+		limited,
+		int64(resp.Limit()),
+		int64(math.Floor(resp.Remaining())),
+		retryAfter,
+		respSeconds(resp.ResetAfter()),
+	)
+}
+
+// respSeconds rounds up so that clients never retry too early.
+func respSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
+
+// splitRESPRoute separates the optional method of an RL.CHECK route.
+func splitRESPRoute(route string) (string, string) {
+	if method, path, found := strings.Cut(route, " "); found {
+		return method, strings.TrimSpace(path)
+	}
+	return "", route
+}
@@ -0,0 +1,181 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type respTestClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestRESPServer(t *testing.T) *respTestClient {
+	t.Helper()
+	return newTestRESPServerWith(t, func(*RESPServer) {})
+}
+
+func newTestRESPServerWith(t *testing.T, configure func(*RESPServer)) *respTestClient {
+	t.Helper()
+	closeChan := make(chan struct{})
+	t.Cleanup(func() { close(closeChan) })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewRESPServer(newTestCheckRouter(closeChan))
+	configure(server)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &respTestClient{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command encoded as a RESP array and returns the reply lines
+// without type prefixes.
+func (c *respTestClient) do(t *testing.T, args ...string) []string {
+	t.Helper()
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&builder, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(builder.String())); err != nil {
+		t.Fatal(err)
+	}
+	return c.readReply(t)
+}
+
+func (c *respTestClient) readReply(t *testing.T) []string {
+	t.Helper()
+	line, err := readRESPLine(c.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "*") {
+		return []string{line}
+	}
+	var count int
+	fmt.Sscanf(line, "*%d", &count)
+	reply := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		item, err := readRESPLine(c.reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply = append(reply, strings.TrimPrefix(item, ":"))
+	}
+	return reply
+}
+
+func TestRESPServer_Throttle(t *testing.T) {
+	client := newTestRESPServer(t)
+
+	// Burst of 2 means 3 requests fit, then 1 per 60 seconds
+	for i := 0; i < 3; i++ {
+		reply := client.do(t, "CL.THROTTLE", "user123", "2", "1", "60")
+		expected := fmt.Sprintf("0|3|%d|-1", 2-i)
+		if got := strings.Join(reply[:4], "|"); got != expected {
+			t.Errorf("Request %d: expected %s, got %s", i+1, expected, got)
+		}
+	}
+
+	reply := client.do(t, "CL.THROTTLE", "user123", "2", "1", "60")
+	if reply[0] != "1" || reply[3] != "60" {
+		t.Errorf("Expected limited reply with retry after 60s, got %v", reply)
+	}
+
+	// Other keys are independent
+	reply = client.do(t, "CL.THROTTLE", "user456", "2", "1", "60", "3")
+	if reply[0] != "0" || reply[2] != "0" {
+		t.Errorf("Expected quantity 3 to use the whole burst of another key, got %v", reply)
+	}
+}
+
+func TestRESPServer_ThrottleQuantityAboveBurst(t *testing.T) {
+	client := newTestRESPServer(t)
+
+	reply := client.do(t, "CL.THROTTLE", "user123", "2", "1", "60", "4")
+	if strings.Join(reply[:4], "|") != "1|3|3|-1" {
+		t.Errorf("Expected a quantity above the burst to be refused without retry time, got %v", reply)
+	}
+	if reply := client.do(t, "CL.THROTTLE", "user123", "2", "1", "60", "3"); reply[0] != "0" {
+		t.Errorf("Expected the refused quantity not to consume the burst, got %v", reply)
+	}
+}
+
+func TestRESPServer_ReadTimeout(t *testing.T) {
+	client := newTestRESPServerWith(t, func(server *RESPServer) {
+		server.ReadTimeout = 50 * time.Millisecond
+	})
+
+	// A command without newline never completes
+	client.conn.Write([]byte("PING"))
+	client.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.reader.ReadByte(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected the server to close the stalled connection, got %v", err)
+	}
+}
+
+func TestRESPServer_Check(t *testing.T) {
+	client := newTestRESPServer(t)
+
+	for i := 0; i < 3; i++ {
+		if reply := client.do(t, "RL.CHECK", "/api/1", "client"); reply[0] != "0" {
+			t.Errorf("Expected request %d to be allowed, got %v", i+1, reply)
+		}
+	}
+	if reply := client.do(t, "RL.CHECK", "/api/1", "client"); reply[0] != "1" || reply[1] != "3" {
+		t.Errorf("Expected limited reply for route capacity 3, got %v", reply)
+	}
+}
+
+func TestRESPServer_InlineAndErrors(t *testing.T) {
+	client := newTestRESPServer(t)
+
+	client.conn.Write([]byte("PING\r\n"))
+	if reply := client.readReply(t); reply[0] != "+PONG" {
+		t.Errorf("Expected +PONG, got %v", reply)
+	}
+
+	if reply := client.do(t, "GET", "key"); !strings.HasPrefix(reply[0], "-ERR unknown command") {
+		t.Errorf("Expected unknown command error, got %v", reply)
+	}
+	if reply := client.do(t, "CL.THROTTLE", "key", "x", "1", "60"); !strings.HasPrefix(reply[0], "-ERR") {
+		t.Errorf("Expected invalid argument error, got %v", reply)
+	}
+	if reply := client.do(t, "RL.CHECK", "/api/1"); !strings.HasPrefix(reply[0], "-ERR wrong number") {
+		t.Errorf("Expected arity error, got %v", reply)
+	}
+}
+
+func TestRESPServer_Pipelining(t *testing.T) {
+	client := newTestRESPServer(t)
+
+	client.conn.Write([]byte("*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPING\r\n"))
+	for i := 0; i < 2; i++ {
+		if reply := client.readReply(t); reply[0] != "+PONG" {
+			t.Errorf("Expected pipelined reply %d to be +PONG, got %v", i+1, reply)
+		}
+	}
+}
+
+func TestSplitRESPRoute(t *testing.T) {
+	if method, path := splitRESPRoute("POST /orders"); method != "POST" || path != "/orders" {
+		t.Errorf("Expected POST /orders, got %q %q", method, path)
+	}
+	if method, path := splitRESPRoute("/orders"); method != "" || path != "/orders" {
+		t.Errorf("Expected a path without method, got %q %q", method, path)
+	}
+}
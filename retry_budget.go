@@ -0,0 +1,103 @@
+package rate_limiter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetryBudget allows retries only up to a percentage of the successful
+// requests seen over a sliding window, plus a minimum number of retries per
+// window so that low traffic can still retry. It prevents retry storms from
+// multiplying the load on an upstream that is already failing. A RetryBudget
+// is safe for concurrent use.
+//
+// Like slidingWindowLogLimiter, it logs a timestamp per success and per retry
+// and prunes the entries that left the window, so its memory is proportional
+// to the requests of one window.
+type RetryBudget struct {
+	successes  []int64
+	retries    []int64
+	ratio      float64
+	minRetries int
+	windowSize time.Duration
+	mutex      sync.Mutex
+}
+
+// NewRetryBudget builds a budget from a retry_budget strategy descriptor.
+func NewRetryBudget(descriptor StrategyDescriptor) (*RetryBudget, error) {
+	if descriptor.StrategyName != RetryStrategyRetryBudget {
+		return nil, fmt.Errorf("unknown retry strategy: %s", descriptor.StrategyName)
+	}
+	params, err := getRetryBudgetParamsFromMap(descriptor.Params)
+	if err != nil {
+		return nil, err
+	}
+	return newRetryBudget(params.Percent/100, params.MinRetries, params.WindowSize), nil
+}
+
+func newRetryBudget(ratio float64, minRetries int, windowSize time.Duration) *RetryBudget {
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		windowSize: windowSize,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// RecordSuccess adds a successful request to the budget.
+func (r *RetryBudget) RecordSuccess() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	// Pruning only before the log would grow keeps successes cheap to record.
+	if len(r.successes) == cap(r.successes) {
+		r.successes = pruneTimestampLog(r.successes, now.Add(-r.windowSize).UnixNano())
+	}
+	r.successes = append(r.successes, now.UnixNano())
+}
+
+// CanRetry reports whether a retry fits in the budget, and withdraws it from
+// the budget if so.
+func (r *RetryBudget) CanRetry() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	windowStart := now.Add(-r.windowSize).UnixNano()
+	r.successes = pruneTimestampLog(r.successes, windowStart)
+	r.retries = pruneTimestampLog(r.retries, windowStart)
+
+	allowed := float64(r.minRetries) + r.ratio*float64(len(r.successes))
+	if float64(len(r.retries)+1) > allowed {
+		return false
+	}
+	r.retries = append(r.retries, now.UnixNano())
+	return true
+}
+
+type retryBudgetParams struct {
+	Percent    float64
+	MinRetries int
+	WindowSize time.Duration
+}
+
+func getRetryBudgetParamsFromMap(params map[string]any) (retryBudgetParams, error) {
+	percent, percentOk := getNumberFromMap[float64](params, "percent")
+	if !percentOk || percent < 0 {
+		return retryBudgetParams{}, errors.New("invalid percent parameter")
+	}
+	minRetries, minRetriesOk := getNumberFromMap[int](params, "min_retries")
+	if !minRetriesOk || minRetries < 0 {
+		return retryBudgetParams{}, errors.New("invalid min_retries parameter")
+	}
+	windowSizeSeconds, windowOk := getNumberFromMap[float64](params, "window_size")
+	if !windowOk || windowSizeSeconds <= 0 {
+		return retryBudgetParams{}, errors.New("invalid window_size parameter")
+	}
+	return retryBudgetParams{
+		Percent:    percent,
+		MinRetries: minRetries,
+		WindowSize: time.Duration(windowSizeSeconds * float64(time.Second)),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudget_MinRetries(t *testing.T) {
+	budget := newRetryBudget(0, 2, time.Minute)
+
+	if !budget.CanRetry() || !budget.CanRetry() {
+		t.Fatal("Expected the minimum retries to be allowed without successes")
+	}
+	if budget.CanRetry() {
+		t.Error("Expected a retry over the minimum to be rejected")
+	}
+}
+
+func TestRetryBudget_PercentOfSuccesses(t *testing.T) {
+	budget := newRetryBudget(0.2, 0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		budget.RecordSuccess()
+	}
+	if !budget.CanRetry() || !budget.CanRetry() {
+		t.Fatal("Expected 20% of 10 successes to allow 2 retries")
+	}
+	if budget.CanRetry() {
+		t.Error("Expected a third retry to be rejected")
+	}
+}
+
+func TestRetryBudget_SlidingWindow(t *testing.T) {
+	budget := newRetryBudget(1, 0, 100*time.Millisecond)
+
+	budget.RecordSuccess()
+	if !budget.CanRetry() {
+		t.Fatal("Expected a retry to be allowed after a success")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if budget.CanRetry() {
+		t.Error("Expected successes to leave the window")
+	}
+	budget.RecordSuccess()
+	if !budget.CanRetry() {
+		t.Error("Expected past retries to leave the window")
+	}
+}
+
+func TestNewRetryBudget(t *testing.T) {
+	budget, err := NewRetryBudget(StrategyDescriptor{
+		StrategyName: RetryStrategyRetryBudget,
+		Params:       map[string]any{"percent": 20, "min_retries": 1, "window_size": 10.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.ratio != 0.2 || budget.minRetries != 1 || budget.windowSize != 10*time.Second {
+		t.Errorf("Unexpected budget %+v", budget)
+	}
+
+	if _, err := NewRetryBudget(StrategyDescriptor{StrategyName: LimiterStrategyFixedWindow}); err == nil {
+		t.Error("Expected an error for another strategy")
+	}
+	if _, err := NewRetryBudget(StrategyDescriptor{
+		StrategyName: RetryStrategyRetryBudget,
+		Params:       map[string]any{"percent": 20, "min_retries": 1},
+	}); err == nil {
+		t.Error("Expected an error without window_size")
+	}
+}
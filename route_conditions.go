@@ -0,0 +1,122 @@
+package rate_limiter
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MatchCondition restricts a route to requests with a header, a query
+// parameter or a content type. A header or query condition compares its
+// values with Equals or Regex, one of them matching being enough, or only
+// requires the attribute to be present when both are empty. ContentType
+// compares the media type, ignoring its parameters, and accepts wildcards
+// like text/*.
+type MatchCondition struct {
+	Header      string `json:"header,omitempty" yaml:"header,omitempty"`
+	Query       string `json:"query,omitempty" yaml:"query,omitempty"`
+	ContentType string `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+	Equals      string `json:"equals,omitempty" yaml:"equals,omitempty"`
+	Regex       string `json:"regex,omitempty" yaml:"regex,omitempty"`
+}
+
+type routeCondition struct {
+	MatchCondition
+	regexp *regexp.Regexp
+}
+
+func compileMatchConditions(conditions []MatchCondition) ([]routeCondition, error) {
+	compiled := make([]routeCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		targets := 0
+		for _, target := range []string{condition.Header, condition.Query, condition.ContentType} {
+			if target != "" {
+				targets++
+			}
+		}
+		if targets != 1 {
+			return nil, errors.New("match condition requires exactly one of header, query or content_type")
+		}
+		if condition.Equals != "" && condition.Regex != "" {
+			return nil, errors.New("match condition accepts either equals or regex")
+		}
+		if condition.ContentType != "" && (condition.Equals != "" || condition.Regex != "") {
+			return nil, errors.New("content_type condition does not accept equals or regex")
+		}
+
+		routeCondition := routeCondition{MatchCondition: condition}
+		if condition.Regex != "" {
+			expression, err := regexp.Compile(condition.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid match regex: %w", err)
+			}
+			routeCondition.regexp = expression
+		}
+		compiled = append(compiled, routeCondition)
+	}
+	return compiled, nil
+}
+
+func (c routeCondition) matches(request RouteRequest) bool {
+	switch {
+	case c.ContentType != "":
+		mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		expected := strings.ToLower(c.ContentType)
+		if prefix, found := strings.CutSuffix(expected, "/*"); found {
+			return strings.HasPrefix(mediaType, prefix+"/")
+		}
+		return mediaType == expected
+	case c.Header != "":
+		return c.matchesValues(request.Header.Values(c.Header))
+	default:
+		return c.matchesValues(request.Query[c.Query])
+	}
+}
+
+// matchesValues reports whether any value of a repeated attribute matches.
+func (c routeCondition) matchesValues(values []string) bool {
+	return slices.ContainsFunc(values, c.matchesValue)
+}
+
+func (c routeCondition) matchesValue(value string) bool {
+	switch {
+	case c.regexp != nil:
+		return c.regexp.MatchString(value)
+	case c.Equals != "":
+		return value == c.Equals
+	}
+	return true
+}
+
+// matchConditionsKey identifies a set of conditions regardless of their
+// order, so routes of the same path can be told apart by their conditions.
+func matchConditionsKey(conditions []MatchCondition) string {
+	keys := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		var key string
+		switch {
+		case condition.ContentType != "":
+			key = "content_type:" + strings.ToLower(condition.ContentType)
+		case condition.Header != "":
+			key = "header:" + http.CanonicalHeaderKey(condition.Header)
+		default:
+			key = "query:" + condition.Query
+		}
+		switch {
+		case condition.Regex != "":
+			key += "~" + condition.Regex
+		case condition.Equals != "":
+			key += "=" + condition.Equals
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return strings.Join(slices.Compact(keys), "&")
+}
@@ -0,0 +1,72 @@
+package rate_limiter
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCompileMatchConditions_Invalid(t *testing.T) {
+	tests := []MatchCondition{
+		{},
+		{Header: "X-Client", Query: "format"},
+		{Header: "X-Client", Equals: "mobile", Regex: "mobile"},
+		{ContentType: "application/json", Equals: "application/json"},
+		{Query: "format", Regex: "("},
+	}
+	for _, condition := range tests {
+		if _, err := compileMatchConditions([]MatchCondition{condition}); err == nil {
+			t.Errorf("Expected %+v to be invalid", condition)
+		}
+	}
+}
+
+func TestRouteCondition_Matches(t *testing.T) {
+	request := RouteRequest{
+		Header: http.Header{
+			"X-Client":     {"web", "mobile"},
+			"Content-Type": {"application/json; charset=utf-8"},
+		},
+		Query: url.Values{"format": {"pdf"}, "draft": {""}},
+	}
+	tests := []struct {
+		condition MatchCondition
+		expected  bool
+	}{
+		{MatchCondition{Header: "x-client", Equals: "mobile"}, true},
+		{MatchCondition{Header: "X-Client", Equals: "tv"}, false},
+		{MatchCondition{Header: "X-Client", Regex: "^mob"}, true},
+		{MatchCondition{Header: "X-Client"}, true},
+		{MatchCondition{Header: "X-Api-Key"}, false},
+		{MatchCondition{Query: "format", Equals: "pdf"}, true},
+		{MatchCondition{Query: "Format", Equals: "pdf"}, false},
+		{MatchCondition{Query: "draft"}, true},
+		{MatchCondition{Query: "format", Regex: "^(csv|xlsx)$"}, false},
+		{MatchCondition{ContentType: "application/json"}, true},
+		{MatchCondition{ContentType: "Application/*"}, true},
+		{MatchCondition{ContentType: "text/*"}, false},
+	}
+	for _, tt := range tests {
+		conditions, err := compileMatchConditions([]MatchCondition{tt.condition})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matches := conditions[0].matches(request); matches != tt.expected {
+			t.Errorf("Expected %+v to match %v, got %v", tt.condition, tt.expected, matches)
+		}
+	}
+	if conditions, _ := compileMatchConditions([]MatchCondition{{ContentType: "application/json"}}); conditions[0].matches(RouteRequest{}) {
+		t.Error("Expected a content type condition not to match requests without one")
+	}
+}
+
+func TestMatchConditionsKey(t *testing.T) {
+	a := matchConditionsKey([]MatchCondition{{Header: "x-client", Equals: "mobile"}, {Query: "format", Equals: "pdf"}})
+	b := matchConditionsKey([]MatchCondition{{Query: "format", Equals: "pdf"}, {Header: "X-Client", Equals: "mobile"}})
+	if a != b {
+		t.Errorf("Expected the key not to depend on order and header case, got %q and %q", a, b)
+	}
+	if a == matchConditionsKey([]MatchCondition{{Header: "X-Client", Regex: "mobile"}, {Query: "format", Equals: "pdf"}}) {
+		t.Error("Expected equals and regex conditions to have different keys")
+	}
+}
@@ -1,9 +1,63 @@
 package rate_limiter
 
-import "strings"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
 
+// Router matches requests to routes. Routes of a host are looked up first,
+// then routes of wildcard hosts from the most specific suffix, then routes for
+// any host.
 type Router struct {
-	root *RouterNode
+	root          *RouterNode
+	hosts         map[string]*RouterNode
+	wildcardHosts map[string]*RouterNode
+	normalization PathNormalization
+	// states holds the limiters of every route by routeDescriptorKey.
+	states map[string]routeState
+}
+
+// MethodAny is the method of routes that match every HTTP method. Routes
+// without methods use it.
+const MethodAny = "ANY"
+
+// methodHeadOfGet holds the routes of GET patterns for HEAD requests, which
+// are tried after the routes for HEAD. It is not a valid method, so no request
+// has it.
+const methodHeadOfGet = "HEAD (GET)"
+
+// RouteRequest holds what a request is matched on. An empty Method or Host
+// only matches routes for any method or host. Header and Query are only
+// needed by routes with match conditions.
+type RouteRequest struct {
+	Host   string
+	Method string
+	Path   string
+	Header http.Header
+	Query  url.Values
+}
+
+// RouteMatch is the route a request matched: its path as configured, and the
+// values of its named variables and catch-all, such as "tenant" for
+// /:tenant/* or "rest" for /files/{rest...}. Params is nil when the route has
+// none.
+type RouteMatch struct {
+	Pattern  string
+	Params   map[string]string
+	pipeline *requestPipeline
+}
+
+// Handle evaluates the matched request of the given cost against the bucket
+// of key, like HandleKeyedRequest.
+func (m RouteMatch) Handle(key string, cost float64) RequestPipelineResponse {
+	return m.pipeline.handleKeyedRequest(key, cost).withRoute(m.Pattern, m.Params)
 }
 
 type RouterNode struct {
@@ -11,62 +65,316 @@ type RouterNode struct {
 	children     map[string]*RouterNode
 	wildCardNode *RouterNode
 	varNode      *RouterNode
-	data         requestPipeline
+	catchAllNode *RouterNode
+	// trailingSlashNode holds the routes ending with {$}, which only match
+	// paths ending with a slash.
+	trailingSlashNode *RouterNode
+	// handlers holds the routes of each method, in the order their
+	// conditions are evaluated.
+	handlers map[string][]routeHandler
+	// constrainedNodes are the variables with a constraint, in the order
+	// they are tried. constraint is set on the nodes themselves.
+	constrainedNodes []*RouterNode
+	constraint       string
+	constraintRegexp *regexp.Regexp
+}
+
+// routeHandler is the pipeline of a route with what describes its matches.
+// paramNames holds the name of every variable and catch-all segment of the
+// route in order, empty for unnamed ones.
+type routeHandler struct {
+	pipeline     *requestPipeline
+	pattern      string
+	paramNames   []string
+	conditions   []routeCondition
+	conditionKey string
+	priority     int
+}
+
+func (h routeHandler) matches(request RouteRequest) bool {
+	for _, condition := range h.conditions {
+		if !condition.matches(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareRouteHandlers orders the routes of a method by descending priority,
+// then by descending number of conditions, then by their conditions.
+func compareRouteHandlers(a, b routeHandler) int {
+	return cmp.Or(
+		cmp.Compare(b.priority, a.priority),
+		cmp.Compare(len(b.conditions), len(a.conditions)),
+		cmp.Compare(a.conditionKey, b.conditionKey),
+	)
+}
+
+func (h routeHandler) match(values []string) RouteMatch {
+	var params map[string]string
+	for i, name := range h.paramNames {
+		if name == "" || i >= len(values) {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string, len(h.paramNames))
+		}
+		params[name] = values[i]
+	}
+	return RouteMatch{Pattern: h.pattern, Params: params, pipeline: h.pipeline}
+}
+
+type segmentKind int
+
+const (
+	segmentStatic segmentKind = iota
+	segmentVar
+	segmentWildcard
+	segmentCatchAll
+	segmentTrailingSlash
+)
+
+// parsePathSegment returns the kind of a route segment, the name of its
+// variable and the constraint of the variable, such as int for :id<int>.
+// Besides :name and *, segments accept the syntax of http.ServeMux patterns:
+// {name} for a variable, {name...} for a trailing catch-all, which can also
+// be written **, and {$} for the end of a path ending with a slash.
+func parsePathSegment(part string) (segmentKind, string, string) {
+	switch {
+	case part == "{$}":
+		return segmentTrailingSlash, "", ""
+	case part == "*":
+		return segmentWildcard, "", ""
+	case part == "**":
+		return segmentCatchAll, "", ""
+	case strings.HasPrefix(part, ":"):
+		name := part[1:]
+		if open := strings.Index(name, "<"); open >= 0 && strings.HasSuffix(name, ">") {
+			return segmentVar, name[:open], name[open+1 : len(name)-1]
+		}
+		return segmentVar, name, ""
+	case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+		name := part[1 : len(part)-1]
+		if name, found := strings.CutSuffix(name, "..."); found {
+			return segmentCatchAll, name, ""
+		}
+		return segmentVar, name, ""
+	}
+	return segmentStatic, part, ""
 }
 
 func newRouter() Router {
 	return Router{
-		root: newNode(""),
+		root:          newNode(""),
+		hosts:         make(map[string]*RouterNode),
+		wildcardHosts: make(map[string]*RouterNode),
+		states:        make(map[string]routeState),
 	}
 }
 
-func (r *Router) setupPath(path string, handler requestPipeline) {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	current := r.root
+// hostRoot returns the trie of a route host, which is either empty for any
+// host, an exact host, or a wildcard such as *.example.com.
+func (r *Router) hostRoot(host string) *RouterNode {
+	host = normalizeHost(host)
+	if host == "" {
+		return r.root
+	}
+	hosts := r.hosts
+	if suffix, found := strings.CutPrefix(host, "*."); found {
+		hosts, host = r.wildcardHosts, suffix
+	}
+	if _, exists := hosts[host]; !exists {
+		hosts[host] = newNode("")
+	}
+	return hosts[host]
+}
+
+// splitRoutePath returns the segments of a route path.
+func splitRoutePath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
 
-	for _, part := range parts {
+func (n *RouterNode) setupPath(path string, methods []string, handler routeHandler) error {
+	parts := splitRoutePath(path)
+	current := n
+	handler.paramNames = nil
 
-		if part == "*" {
+	for i, part := range parts {
+		kind, name, constraint := parsePathSegment(part)
+		if kind == segmentVar || kind == segmentCatchAll {
+			handler.paramNames = append(handler.paramNames, name)
+		}
+		switch {
+		// A constraint with a slash is split with the path, which leaves it
+		// unterminated
+		case kind == segmentVar && strings.Contains(name, "<"):
+			return fmt.Errorf("unterminated constraint in segment %q, constraints cannot contain /", part)
+		case kind == segmentVar && constraint != "":
+			child, err := current.constrainedNode(constraint)
+			if err != nil {
+				return err
+			}
+			current = child
+		case kind == segmentWildcard:
 			if current.wildCardNode == nil {
 				current.wildCardNode = newNode(part)
 			}
 			current = current.wildCardNode
-			continue
-		}
-
-		if strings.HasPrefix(part, ":") {
+		case kind == segmentVar:
 			if current.varNode == nil {
 				current.varNode = newNode(part)
 			}
 			current = current.varNode
-			continue
+		case kind == segmentCatchAll:
+			if i != len(parts)-1 {
+				return errors.New("catch-all must be the last segment of a path")
+			}
+			if current.catchAllNode == nil {
+				current.catchAllNode = newNode(part)
+			}
+			current = current.catchAllNode
+		case kind == segmentTrailingSlash:
+			if i != len(parts)-1 {
+				return errors.New("{$} must be the last segment of a path")
+			}
+			// /{$} is the root path, which has a single empty segment
+			if i == 0 {
+				if _, exists := current.children[""]; !exists {
+					current.children[""] = newNode("")
+				}
+				current = current.children[""]
+				break
+			}
+			if current.trailingSlashNode == nil {
+				current.trailingSlashNode = newNode(part)
+			}
+			current = current.trailingSlashNode
+		default:
+			if _, exists := current.children[part]; !exists {
+				current.children[part] = newNode(part)
+			}
+			current = current.children[part]
 		}
+	}
+	for _, method := range normalizeMethods(methods) {
+		handlers := slices.DeleteFunc(current.handlers[method], func(existing routeHandler) bool {
+			return existing.conditionKey == handler.conditionKey
+		})
+		handlers = append(handlers, handler)
+		slices.SortStableFunc(handlers, compareRouteHandlers)
+		current.handlers[method] = handlers
+	}
+	return nil
+}
 
-		if _, exists := current.children[part]; !exists {
-			current.children[part] = newNode(part)
+// constrainedNode returns the child of the variables with constraint,
+// keeping the children in the order of compareConstraints.
+func (n *RouterNode) constrainedNode(constraint string) (*RouterNode, error) {
+	index, exists := slices.BinarySearchFunc(n.constrainedNodes, constraint, func(node *RouterNode, constraint string) int {
+		return compareConstraints(node.constraint, constraint)
+	})
+	if exists {
+		return n.constrainedNodes[index], nil
+	}
+	compiled, err := compileSegmentConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	child := newNode(":<" + constraint + ">")
+	child.constraint = constraint
+	child.constraintRegexp = compiled
+	n.constrainedNodes = slices.Insert(n.constrainedNodes, index, child)
+	return child, nil
+}
+
+// evalRoute finds the pipeline of a request in the tries of its host, its
+// wildcard hosts and any host, in that order. Within a trie, it is the most
+// specific path that has a handler for the method or for MethodAny, with
+// static segments first, then constrained variables whose constraint matches
+// the segment, variables, wildcards and catch-alls; a path whose handlers are
+// all for other methods does not match, so less specific paths are tried.
+//
+// A path ending with a slash matches the routes of the path without it, after
+// those ending with {$}.
+func (r *Router) evalRoute(request RouteRequest) (RouteMatch, bool) {
+	parts, keys, trailingSlash := r.normalization.requestParts(request.Path)
+	for _, root := range r.hostRoots(request.Host) {
+		if match, found := root.evalPath(request, parts, keys, trailingSlash); found {
+			return match, true
 		}
-		current = current.children[part]
 	}
-	current.data = handler
+	return RouteMatch{}, false
 }
 
-func (r *Router) evalRoute(path string) (requestPipeline, bool) {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
+// hostRoots returns the tries that may hold routes for host, from the most
+// specific.
+func (r *Router) hostRoots(host string) []*RouterNode {
+	host = normalizeHost(host)
+	roots := make([]*RouterNode, 0, 3)
+	if host != "" {
+		if root, exists := r.hosts[host]; exists {
+			roots = append(roots, root)
+		}
+		// *.example.com matches subdomains at any depth, but not example.com
+		for suffix := host; ; {
+			_, rest, found := strings.Cut(suffix, ".")
+			if !found {
+				break
+			}
+			if root, exists := r.wildcardHosts[rest]; exists {
+				roots = append(roots, root)
+			}
+			suffix = rest
+		}
+	}
+	return append(roots, r.root)
+}
 
+// evalPath matches the segments of a path, looking up static segments by
+// their keys and capturing variables from parts.
+func (n *RouterNode) evalPath(request RouteRequest, parts, keys []string, trailingSlash bool) (RouteMatch, bool) {
 	type stackFrame struct {
 		node      *RouterNode
 		partIndex int
-		state     int // 0: to visit static, 1: to visit var, 2: to visit wildcard
+		state     int // 0: to visit static, 1: to visit constrained vars, 2: to visit var, 3: to visit wildcard, 4: to visit catch-all
+		captured  bool
+		// constrained is the next constrained var to visit in state 1
+		constrained int
 	}
 
-	stack := []stackFrame{{node: r.root, partIndex: 0, state: 0}}
+	stack := []stackFrame{{node: n, partIndex: 0, state: 0}}
+	// match captures the segments of the variables on the stack, then the
+	// rest of the path for a catch-all.
+	match := func(handler routeHandler, catchAll bool) RouteMatch {
+		var values []string
+		for _, frame := range stack {
+			if frame.captured {
+				values = append(values, parts[frame.partIndex-1])
+			}
+		}
+		if catchAll {
+			values = append(values, strings.Join(parts[stack[len(stack)-1].partIndex:], "/"))
+		}
+		return handler.match(values)
+	}
 
 	for len(stack) > 0 {
 		frame := &stack[len(stack)-1]
 
 		if frame.partIndex == len(parts) {
-			if frame.node.data != (requestPipeline{}) {
-				return frame.node.data, true
+			if trailingSlash && frame.node.trailingSlashNode != nil {
+				if handler, found := frame.node.trailingSlashNode.handler(request); found {
+					return match(handler, false), true
+				}
+			}
+			if handler, found := frame.node.handler(request); found {
+				return match(handler, false), true
+			}
+			// A catch-all also matches when no segment is left
+			if frame.node.catchAllNode != nil {
+				if handler, found := frame.node.catchAllNode.handler(request); found {
+					return match(handler, true), true
+				}
 			}
 			stack = stack[:len(stack)-1]
 			continue
@@ -77,39 +385,141 @@ func (r *Router) evalRoute(path string) (requestPipeline, bool) {
 		switch frame.state {
 		case 0:
 			frame.state = 1
-			if child, exists := frame.node.children[part]; exists {
+			if child, exists := frame.node.children[keys[frame.partIndex]]; exists {
 				stack = append(stack, stackFrame{node: child, partIndex: frame.partIndex + 1, state: 0})
 			}
 		case 1:
-			frame.state = 2
-			if frame.node.varNode != nil {
-				stack = append(stack, stackFrame{node: frame.node.varNode, partIndex: frame.partIndex + 1, state: 0})
+			if frame.constrained == len(frame.node.constrainedNodes) {
+				frame.state = 2
+				continue
+			}
+			child := frame.node.constrainedNodes[frame.constrained]
+			frame.constrained++
+			if child.constraintRegexp.MatchString(part) {
+				stack = append(stack, stackFrame{node: child, partIndex: frame.partIndex + 1, state: 0, captured: true})
 			}
 		case 2:
 			frame.state = 3
+			if frame.node.varNode != nil {
+				stack = append(stack, stackFrame{node: frame.node.varNode, partIndex: frame.partIndex + 1, state: 0, captured: true})
+			}
+		case 3:
+			frame.state = 4
 			if frame.node.wildCardNode != nil {
 				stack = append(stack, stackFrame{node: frame.node.wildCardNode, partIndex: frame.partIndex + 1, state: 0})
 			}
-		case 3:
+		case 4:
+			frame.state = 5
+			if frame.node.catchAllNode != nil {
+				if handler, found := frame.node.catchAllNode.handler(request); found {
+					return match(handler, true), true
+				}
+			}
+		case 5:
 			stack = stack[:len(stack)-1]
 		}
 	}
 
-	return requestPipeline{}, false
+	return RouteMatch{}, false
+}
+
+// HandleRequest evaluates a request with the given HTTP method. An empty
+// method only matches routes for any method.
+func (r Router) HandleRequest(method, path string) (RequestPipelineResponse, bool) {
+	return r.HandleRouteRequest(RouteRequest{Method: method, Path: path}, "", 1)
 }
 
-func (r Router) HandleRequest(path string) (RequestPipelineResponse, bool) {
-	pipeline, found := r.evalRoute(path)
+// HandleKeyedRequest evaluates a request of the given cost against the
+// bucket of key, such as a client ID or address, within the matched route.
+// Every key gets its own limiter built from the route configuration; an empty
+// key uses the route-wide limiter like HandleRequest.
+func (r Router) HandleKeyedRequest(method, path, key string, cost float64) (RequestPipelineResponse, bool) {
+	return r.HandleRouteRequest(RouteRequest{Method: method, Path: path}, key, cost)
+}
+
+// HandleRouteRequest is HandleKeyedRequest for requests that are also
+// matched on their host.
+func (r Router) HandleRouteRequest(request RouteRequest, key string, cost float64) (RequestPipelineResponse, bool) {
+	match, found := r.evalRoute(request)
 	if !found {
 		return newSyncRequestPipelineResponse(true), found
 	}
-	return pipeline.handleRequest(), true
+	return match.Handle(key, cost), true
+}
+
+// MatchRoute finds the route of a request without evaluating it, so that the
+// key of the request can depend on the match, such as a path variable.
+func (r Router) MatchRoute(request RouteRequest) (RouteMatch, bool) {
+	return r.evalRoute(request)
+}
+
+// BandwidthLimiters returns the upload and download limiters of key, such as
+// a client address, within the matched route. It reports false when the route
+// does not limit bandwidth.
+func (r Router) BandwidthLimiters(request RouteRequest, key string) (*BandwidthLimiter, *BandwidthLimiter, bool) {
+	match, found := r.evalRoute(request)
+	if !found || match.pipeline.bandwidth == nil {
+		return nil, nil, false
+	}
+	upload, download := match.pipeline.bandwidth.limiters(key)
+	return upload, download, true
 }
 
 func newNode(part string) *RouterNode {
 	return &RouterNode{
 		pathPart: part,
 		children: make(map[string]*RouterNode),
-		data:     requestPipeline{},
+		handlers: make(map[string][]routeHandler),
+	}
+}
+
+// handler returns the first route of the request method whose conditions
+// hold, or else of MethodAny.
+func (n *RouterNode) handler(request RouteRequest) (routeHandler, bool) {
+	if request.Method != "" {
+		method := strings.ToUpper(request.Method)
+		if handler, found := firstMatchingHandler(n.handlers[method], request); found {
+			return handler, true
+		}
+		if method == http.MethodHead {
+			if handler, found := firstMatchingHandler(n.handlers[methodHeadOfGet], request); found {
+				return handler, true
+			}
+		}
+	}
+	return firstMatchingHandler(n.handlers[MethodAny], request)
+}
+
+func firstMatchingHandler(handlers []routeHandler, request RouteRequest) (routeHandler, bool) {
+	for _, handler := range handlers {
+		if handler.matches(request) {
+			return handler, true
+		}
+	}
+	return routeHandler{}, false
+}
+
+// normalizeMethods upper-cases methods, and returns MethodAny for routes
+// without methods.
+func normalizeMethods(methods []string) []string {
+	normalized := make([]string, 0, max(len(methods), 1))
+	for _, method := range methods {
+		if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+			normalized = append(normalized, method)
+		}
+	}
+	if len(normalized) == 0 {
+		normalized = append(normalized, MethodAny)
+	}
+	return normalized
+}
+
+// normalizeHost lower-cases a host and strips its port and trailing dot, so
+// that the Host header of a request matches the host of a route.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
 	}
+	return strings.TrimSuffix(strings.Trim(host, "[]"), ".")
 }
@@ -0,0 +1,259 @@
+package rate_limiter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type RouteIssueSeverity string
+
+const (
+	// RouteIssueWarning marks routes that work, but likely not as intended.
+	RouteIssueWarning RouteIssueSeverity = "warning"
+	// RouteIssueError marks routes that cannot be built deterministically.
+	RouteIssueError RouteIssueSeverity = "error"
+)
+
+// RouteIssue is a problem found in the route table by RouterBuilder.Analyze.
+type RouteIssue struct {
+	Severity RouteIssueSeverity
+	Message  string
+}
+
+func (i RouteIssue) String() string {
+	return string(i.Severity) + ": " + i.Message
+}
+
+// RouteTableError is returned by Build when the route table has errors. It
+// holds every issue found, including warnings.
+type RouteTableError struct {
+	Issues []RouteIssue
+}
+
+func (e *RouteTableError) Error() string {
+	messages := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		if issue.Severity == RouteIssueError {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return "invalid route table: " + strings.Join(messages, "; ")
+}
+
+// analyzedRoute is a route resolved the way setupRoute adds it to the trie.
+type analyzedRoute struct {
+	label      string
+	host       string
+	segments   []analyzedSegment
+	methods    []string
+	conditions string
+}
+
+type analyzedSegment struct {
+	kind       segmentKind
+	name       string
+	constraint string
+}
+
+// shape identifies the segments that match the same requests, whatever the
+// names of their variables.
+func (s analyzedSegment) shape() string {
+	switch s.kind {
+	case segmentVar:
+		if s.constraint != "" {
+			return ":<" + s.constraint + ">"
+		}
+		return ":"
+	case segmentWildcard:
+		return "*"
+	case segmentCatchAll:
+		return "**"
+	case segmentTrailingSlash:
+		return "{$}"
+	}
+	return "/" + s.name
+}
+
+func newAnalyzedRoute(route RouteDescriptor, normalization PathNormalization) analyzedRoute {
+	resolved := resolveRoutePattern(route)
+	methods := normalizeMethods(resolved.Methods)
+	slices.Sort(methods)
+	methods = slices.Compact(methods)
+
+	parts := splitRoutePath(normalization.routePath(resolved.Path))
+	segments := make([]analyzedSegment, len(parts))
+	for i, part := range parts {
+		kind, name, constraint := parsePathSegment(part)
+		segments[i] = analyzedSegment{kind: kind, name: name, constraint: constraint}
+	}
+
+	label := route.Path
+	if route.Host != "" {
+		label = route.Host + " " + label
+	}
+	if len(route.Methods) > 0 {
+		label = strings.Join(route.Methods, ",") + " " + label
+	}
+	conditions := matchConditionsKey(route.Match)
+	if conditions != "" {
+		label += " [" + conditions + "]"
+	}
+	return analyzedRoute{
+		label:      label,
+		host:       normalizeHost(resolved.Host),
+		segments:   segments,
+		methods:    methods,
+		conditions: conditions,
+	}
+}
+
+// covers reports whether every request of other that reaches the path of a
+// also meets its methods and conditions.
+func (a analyzedRoute) covers(other analyzedRoute) bool {
+	if a.conditions != "" && a.conditions != other.conditions {
+		return false
+	}
+	return a.coversMethods(other)
+}
+
+// coversMethods reports whether every method of other also reaches a.
+func (a analyzedRoute) coversMethods(other analyzedRoute) bool {
+	if slices.Contains(a.methods, MethodAny) {
+		return true
+	}
+	if slices.Contains(other.methods, MethodAny) {
+		return false
+	}
+	for _, method := range other.methods {
+		if !slices.Contains(a.methods, method) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a analyzedRoute) sharedMethod(other analyzedRoute) (string, bool) {
+	for _, method := range a.methods {
+		if slices.Contains(other.methods, method) {
+			return method, true
+		}
+	}
+	return "", false
+}
+
+// Analyze checks the route table for routes that are not matched the way
+// they are written:
+//   - errors for routes matching the same requests once patterns and paths
+//     are normalized, such as /users/:id and /users/{name}, as only one of
+//     them would be kept;
+//   - warnings for variables with different names sharing a segment, such as
+//     /users/:id and /users/:name/posts;
+//   - warnings for routes shadowed by more specific ones, such as /users/*
+//     by /users/:id, since variables are tried before wildcards.
+func (r *RouterBuilder) Analyze() []RouteIssue {
+	routes := make([]analyzedRoute, 0, len(r.descriptors))
+	for _, route := range r.descriptors {
+		routes = append(routes, newAnalyzedRoute(route, r.normalization))
+	}
+	slices.SortFunc(routes, func(a, b analyzedRoute) int {
+		return cmp.Compare(a.label, b.label)
+	})
+
+	var issues []RouteIssue
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			issues = append(issues, compareRoutes(routes[i], routes[j])...)
+		}
+	}
+	return issues
+}
+
+func compareRoutes(a, b analyzedRoute) []RouteIssue {
+	if a.host != b.host {
+		return nil
+	}
+
+	var issues []RouteIssue
+	common := min(len(a.segments), len(b.segments))
+	for i := 0; i < common; i++ {
+		if a.segments[i].shape() != b.segments[i].shape() && !singleSegments(a.segments[i], b.segments[i]) {
+			break
+		}
+		if a.segments[i].kind == segmentVar && b.segments[i].kind == segmentVar && a.segments[i].name != b.segments[i].name {
+			issues = append(issues, RouteIssue{
+				Severity: RouteIssueWarning,
+				Message:  fmt.Sprintf("variables :%s of %q and :%s of %q share segment %d", a.segments[i].name, a.label, b.segments[i].name, b.label, i+1),
+			})
+			break
+		}
+	}
+
+	if len(a.segments) != len(b.segments) {
+		return issues
+	}
+	// Where a variable and a wildcard first differ, the variable is tried
+	// first, so its route shadows the other if it also covers the segments
+	// that follow.
+	shadowing := 0
+	for i := range a.segments {
+		sa, sb := a.segments[i], b.segments[i]
+		switch {
+		case sa.shape() == sb.shape():
+		case shadowing == 0 && singleSegments(sa, sb):
+			shadowing = 1
+			if sb.kind == segmentVar {
+				shadowing = 2
+			}
+		case shadowing == 1 && coversSegment(sa, sb):
+		case shadowing == 2 && coversSegment(sb, sa):
+		default:
+			return issues
+		}
+	}
+
+	switch shadowing {
+	case 0:
+		if method, overlaps := a.sharedMethod(b); overlaps && a.conditions == b.conditions {
+			issues = append(issues, RouteIssue{
+				Severity: RouteIssueError,
+				Message:  fmt.Sprintf("routes %q and %q match the same %s requests", a.label, b.label, method),
+			})
+		}
+	case 1:
+		if a.covers(b) {
+			issues = append(issues, shadowedRouteIssue(b, a))
+		}
+	case 2:
+		if b.covers(a) {
+			issues = append(issues, shadowedRouteIssue(a, b))
+		}
+	}
+	return issues
+}
+
+// singleSegments reports whether two segments are a variable without
+// constraint and a wildcard, which match the same single segments.
+func singleSegments(a, b analyzedSegment) bool {
+	return (a.unconstrainedVar() && b.kind == segmentWildcard) || (a.kind == segmentWildcard && b.unconstrainedVar())
+}
+
+// coversSegment reports whether a matches every segment b matches.
+func coversSegment(a, b analyzedSegment) bool {
+	if a.shape() == b.shape() {
+		return true
+	}
+	return (a.unconstrainedVar() || a.kind == segmentWildcard) && b.kind != segmentCatchAll && b.kind != segmentTrailingSlash
+}
+
+func (s analyzedSegment) unconstrainedVar() bool {
+	return s.kind == segmentVar && s.constraint == ""
+}
+
+func shadowedRouteIssue(shadowed, by analyzedRoute) RouteIssue {
+	return RouteIssue{
+		Severity: RouteIssueWarning,
+		Message:  fmt.Sprintf("route %q is shadowed by %q and never matches", shadowed.label, by.label),
+	}
+}
@@ -0,0 +1,158 @@
+package rate_limiter
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func analyzeTestRoutes(routes ...RouteDescriptor) (RouterBuilder, []RouteIssue) {
+	builder := NewRouterBuilder(make(chan struct{}))
+	for _, route := range routes {
+		builder.SetRoute(route)
+	}
+	return builder, builder.Analyze()
+}
+
+func TestRouterBuilder_AnalyzeDuplicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []RouteDescriptor
+	}{
+		{"variable names", []RouteDescriptor{
+			newTestFixedWindowRoute("/users/:id", nil, 1),
+			newTestFixedWindowRoute("/users/{name}", nil, 1),
+		}},
+		{"trailing slash", []RouteDescriptor{
+			newTestFixedWindowRoute("/users/", nil, 1),
+			newTestFixedWindowRoute("/users", nil, 1),
+		}},
+		{"pattern trailing slash", []RouteDescriptor{
+			newTestFixedWindowRoute("GET /users/", nil, 1),
+			newTestFixedWindowRoute("GET /users/{rest...}", nil, 1),
+		}},
+		{"catch-all syntax", []RouteDescriptor{
+			newTestFixedWindowRoute("/files/**", nil, 1),
+			newTestFixedWindowRoute("/files/{rest...}", nil, 1),
+		}},
+		{"constraint", []RouteDescriptor{
+			newTestFixedWindowRoute("/users/:id<int>", nil, 1),
+			newTestFixedWindowRoute("/users/:n<int>", nil, 1),
+		}},
+		{"pattern method", []RouteDescriptor{
+			newTestFixedWindowRoute("POST /orders", nil, 1),
+			newTestFixedWindowRoute("/orders", []string{http.MethodPost, http.MethodPut}, 1),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder, issues := analyzeTestRoutes(tt.routes...)
+			if len(issues) == 0 || issues[len(issues)-1].Severity != RouteIssueError {
+				t.Fatalf("Expected a duplicate route error, got %v", issues)
+			}
+			_, err := builder.Build()
+			var tableErr *RouteTableError
+			if !errors.As(err, &tableErr) {
+				t.Fatalf("Expected Build to fail with a RouteTableError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRouterBuilder_AnalyzeDistinctRoutes(t *testing.T) {
+	hostRoute := newTestFixedWindowRoute("/users/:id", nil, 1)
+	hostRoute.Host = "api.example.com"
+	_, issues := analyzeTestRoutes(
+		newTestFixedWindowRoute("/users/:id", nil, 1),
+		newTestFixedWindowRoute("/users/:id", []string{http.MethodPost}, 1),
+		newTestFixedWindowRoute("/users/me", nil, 1),
+		newTestFixedWindowRoute("/users/:id<int>", nil, 1),
+		newTestFixedWindowRoute("/users/:id<int>/posts", nil, 1),
+		newTestFixedWindowRoute("/users/:id/posts", nil, 1),
+		newTestFixedWindowRoute("/users/**", nil, 1),
+		newTestFixedWindowRoute("/users/{$}", nil, 1),
+		newTestFixedWindowRoute("GET /users", nil, 1),
+		newTestFixedWindowRoute("HEAD /users", nil, 1),
+		hostRoute,
+	)
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestRouterBuilder_AnalyzeConflictingNames(t *testing.T) {
+	builder, issues := analyzeTestRoutes(
+		newTestFixedWindowRoute("/users/:id", nil, 1),
+		newTestFixedWindowRoute("/users/:name/posts", nil, 1),
+	)
+	if len(issues) != 1 || issues[0].Severity != RouteIssueWarning || !strings.Contains(issues[0].Message, ":name") {
+		t.Fatalf("Expected a warning about the variable names, got %v", issues)
+	}
+	if _, err := builder.Build(); err != nil {
+		t.Errorf("Expected warnings not to fail the build, got %v", err)
+	}
+}
+
+func TestRouterBuilder_AnalyzeShadowedRoutes(t *testing.T) {
+	_, issues := analyzeTestRoutes(
+		newTestFixedWindowRoute("/users/*/posts", []string{http.MethodGet}, 1),
+		newTestFixedWindowRoute("/users/:id/*", nil, 1),
+	)
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, `"GET /users/*/posts" is shadowed`) {
+		t.Fatalf("Expected the wildcard route to be shadowed, got %v", issues)
+	}
+
+	// A constrained variable leaves the segments it rejects to the wildcard
+	_, issues = analyzeTestRoutes(
+		newTestFixedWindowRoute("/users/*", nil, 1),
+		newTestFixedWindowRoute("/users/:id<int>", nil, 1),
+	)
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+
+	// A route that only covers some methods leaves the others reachable
+	_, issues = analyzeTestRoutes(
+		newTestFixedWindowRoute("/users/*", nil, 1),
+		newTestFixedWindowRoute("/users/:id", []string{http.MethodGet}, 1),
+	)
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestRouterBuilder_BuildInvalidRoute(t *testing.T) {
+	builder, _ := analyzeTestRoutes(newTestFixedWindowRoute("/files/**/raw", nil, 1))
+	if _, err := builder.Build(); err == nil {
+		t.Error("Expected an invalid route to fail the build")
+	}
+}
+
+func TestRouterBuilder_AnalyzeMatchConditions(t *testing.T) {
+	export := newTestFixedWindowRoute("/reports/:id", nil, 1)
+	export.Match = []MatchCondition{{Query: "format", Equals: "pdf"}}
+	_, issues := analyzeTestRoutes(
+		export,
+		newTestFixedWindowRoute("/reports/:id", nil, 1),
+		newTestFixedWindowRoute("/reports/*/raw", nil, 1),
+		newTestFixedWindowRoute("/reports/:id/raw", []string{http.MethodPost}, 1),
+	)
+	if len(issues) != 0 {
+		t.Errorf("Expected routes with different conditions to coexist, got %v", issues)
+	}
+
+	csvExport := newTestFixedWindowRoute("/reports/*", nil, 1)
+	csvExport.Match = []MatchCondition{{Query: "format", Equals: "csv"}}
+	_, issues = analyzeTestRoutes(export, csvExport)
+	if len(issues) != 0 {
+		t.Errorf("Expected a conditional route only to shadow routes with the same conditions, got %v", issues)
+	}
+
+	sameConditions := newTestFixedWindowRoute("/reports/{name}", nil, 1)
+	sameConditions.Match = []MatchCondition{{Query: "format", Equals: "pdf"}}
+	_, issues = analyzeTestRoutes(export, sameConditions)
+	if len(issues) != 2 || issues[1].Severity != RouteIssueError {
+		t.Errorf("Expected routes with the same conditions to be duplicates, got %v", issues)
+	}
+}
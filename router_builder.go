@@ -1,9 +1,16 @@
 package rate_limiter
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,10 +18,12 @@ import (
 type StrategyName string
 
 const (
-	LimiterStrategyFixedWindow      StrategyName = "fixed_window"
-	LimiterStrategyTokenBucket      StrategyName = "token_bucket"
-	LimiterStrategySlidingWindowLog StrategyName = "sliding_window_log"
-	TrafficStrategyLeakyBucket      StrategyName = "leaky_bucket"
+	LimiterStrategyFixedWindow          StrategyName = "fixed_window"
+	LimiterStrategyTokenBucket          StrategyName = "token_bucket"
+	LimiterStrategySlidingWindowLog     StrategyName = "sliding_window_log"
+	LimiterStrategySlidingWindowCounter StrategyName = "sliding_window_counter"
+	TrafficStrategyLeakyBucket          StrategyName = "leaky_bucket"
+	RetryStrategyRetryBudget            StrategyName = "retry_budget"
 )
 
 type StrategyDescriptor struct {
@@ -22,38 +31,177 @@ type StrategyDescriptor struct {
 	Params       map[string]any `json:"params" yaml:"params"`
 }
 
+// RouteDescriptor configures the limits of a path. Methods restricts the
+// route to some HTTP methods; without methods it applies to any method, and
+// method routes of the same path take precedence over it. Host restricts the
+// route to a host, or to its subdomains with a wildcard like
+// *.tenant.example.com; routes of an exact host take precedence over routes
+// of wildcard hosts, which take precedence over routes for any host. Match
+// restricts the route to requests meeting every condition; routes of the same
+// path and method are tried by descending Priority, then from the most
+// conditions, and a route without conditions comes last.
 type RouteDescriptor struct {
-	Path                    string              `json:"path" yaml:"path"`
-	LimiterDescriptor       *StrategyDescriptor `json:"limiter,omitempty" yaml:"limiter,omitempty"`
-	TrafficShaperDescriptor *StrategyDescriptor `json:"traffic,omitempty" yaml:"traffic,omitempty"`
+	Host                    string               `json:"host,omitempty" yaml:"host,omitempty"`
+	Path                    string               `json:"path" yaml:"path"`
+	Methods                 []string             `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Match                   []MatchCondition     `json:"match,omitempty" yaml:"match,omitempty"`
+	Priority                int                  `json:"priority,omitempty" yaml:"priority,omitempty"`
+	LimiterDescriptor       *StrategyDescriptor  `json:"limiter,omitempty" yaml:"limiter,omitempty"`
+	TrafficShaperDescriptor *StrategyDescriptor  `json:"traffic,omitempty" yaml:"traffic,omitempty"`
+	BackendDescriptor       *BackendDescriptor   `json:"backend,omitempty" yaml:"backend,omitempty"`
+	BandwidthDescriptor     *BandwidthDescriptor `json:"bandwidth,omitempty" yaml:"bandwidth,omitempty"`
 }
 
 type RouterBuilder struct {
-	descriptors  map[string]RouteDescriptor
-	closeSignal <-chan struct{}
+	descriptors   map[string]RouteDescriptor
+	backends      map[string]Backend
+	normalization PathNormalization
+	closeSignal   <-chan struct{}
 }
 
 func NewRouterBuilder(closeSign <-chan struct{}) RouterBuilder {
 	return RouterBuilder{
 		descriptors: make(map[string]RouteDescriptor),
+		backends:    make(map[string]Backend),
 		closeSignal: closeSign,
 	}
 }
 
-func (r *RouterBuilder) Build() Router {
+// Build creates the router of the route table. It fails with a
+// *RouteTableError when Analyze finds errors, and with the error of the first
+// invalid route otherwise. Warnings do not fail the build; call Analyze to
+// report them.
+func (r *RouterBuilder) Build() (Router, error) {
+	issues := r.Analyze()
+	for _, issue := range issues {
+		if issue.Severity == RouteIssueError {
+			return Router{}, &RouteTableError{Issues: issues}
+		}
+	}
+
 	router := newRouter()
+	router.normalization = r.normalization
 	for _, route := range r.descriptors {
-		router.setupRoute(route, r.closeSignal)
+		if err := router.setupRoute(route, r.backends, r.closeSignal); err != nil {
+			return Router{}, fmt.Errorf("route %s: %w", route.Path, err)
+		}
 	}
-	return router
+	return router, nil
+}
+
+// RegisterBackend makes a shared backend available to routes whose backend
+// descriptor references it by name.
+func (r *RouterBuilder) RegisterBackend(name string, backend Backend) {
+	r.backends[name] = backend
 }
 
+// SetPathNormalization sets how the paths of routes and requests are
+// normalized before matching.
+func (r *RouterBuilder) SetPathNormalization(normalization PathNormalization) {
+	r.normalization = normalization
+}
+
+// SetRoute adds a route, replacing the one with the same host, path, methods
+// and match conditions.
 func (r *RouterBuilder) SetRoute(route RouteDescriptor) {
-	r.descriptors[route.Path] = route
+	r.descriptors[routeDescriptorKey(route)] = route
+}
+
+// RemoveRoute removes the route of path for any host with the given methods
+// and without match conditions, or every such route of path, with or without
+// conditions, when no methods are given.
+func (r *RouterBuilder) RemoveRoute(path string, methods ...string) {
+	r.RemoveHostRoute("", path, methods...)
+}
+
+// RemoveHostRoute is RemoveRoute for the routes of host.
+func (r *RouterBuilder) RemoveHostRoute(host, path string, methods ...string) {
+	target := resolveRoutePattern(RouteDescriptor{Host: host, Path: path, Methods: methods})
+	if len(target.Methods) > 0 {
+		delete(r.descriptors, routeDescriptorKey(target))
+		return
+	}
+	for key, route := range r.descriptors {
+		route = resolveRoutePattern(route)
+		if normalizeHost(route.Host) == normalizeHost(target.Host) && route.Path == target.Path {
+			delete(r.descriptors, key)
+		}
+	}
 }
 
-func (r *RouterBuilder) RemoveRoute(path string) {
-	delete(r.descriptors, path)
+func routeDescriptorKey(route RouteDescriptor) string {
+	route = resolveRoutePattern(route)
+	normalized := normalizeMethods(route.Methods)
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	key := strings.Join(normalized, ",") + " " + normalizeHost(route.Host) + route.Path
+	if len(route.Match) > 0 {
+		key += " " + matchConditionsKey(route.Match)
+	}
+	return key
+}
+
+// resolveRoutePattern moves the method and host of a path written as an
+// http.ServeMux pattern, such as "GET example.com/items/{id}", to the Methods
+// and Host of route. A host is only read from patterns with a method, so that
+// a path without a leading slash such as "api/v1/users" stays a path; routes
+// for a host without a method set Host. A Host set on the descriptor takes
+// precedence over the host of the pattern.
+//
+// Like in ServeMux, GET also matches HEAD, but after the routes for HEAD, and
+// a pattern ending with a slash matches every path below it, so it gets a
+// trailing catch-all: "GET /static/" is /static/**. Both only apply to
+// patterns with a method or {} segments; other paths keep matching as they
+// always did, with /api/ matching only /api.
+func resolveRoutePattern(route RouteDescriptor) RouteDescriptor {
+	pattern := strings.TrimSpace(route.Path)
+	serveMux := false
+	if space := strings.IndexAny(pattern, " \t"); space >= 0 && isMethodToken(pattern[:space]) {
+		serveMux = true
+		method := pattern[:space]
+		route.Methods = append(slices.Clone(route.Methods), method)
+		if strings.EqualFold(method, http.MethodGet) {
+			route.Methods = append(route.Methods, methodHeadOfGet)
+		}
+		pattern = strings.TrimLeft(pattern[space:], " \t")
+		if slash := strings.Index(pattern, "/"); slash > 0 {
+			if route.Host == "" {
+				route.Host = pattern[:slash]
+			}
+			pattern = pattern[slash:]
+		}
+	}
+	if (serveMux || hasServeMuxSegments(pattern)) && strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	route.Path = pattern
+	return route
+}
+
+// hasServeMuxSegments reports whether a path has segments in the {} syntax of
+// http.ServeMux patterns.
+func hasServeMuxSegments(path string) bool {
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			return true
+		}
+	}
+	return false
+}
+
+// isMethodToken reports whether value can be the method of a pattern. Paths
+// never are, as their slashes are not allowed in HTTP tokens.
+func isMethodToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		isAlphanumeric := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+	return true
 }
 
 func (r *RouterBuilder) GetRouteDescriptors() []RouteDescriptor {
@@ -64,16 +212,40 @@ func (r *RouterBuilder) GetRouteDescriptors() []RouteDescriptor {
 	return descriptors
 }
 
-func (r *Router) setupRoute(route RouteDescriptor, closeSign <-chan struct{}) error {
+func (r *Router) setupRoute(route RouteDescriptor, backends map[string]Backend, closeSign <-chan struct{}) error {
+	pattern := route.Path
+	key := routeDescriptorKey(route)
+	route = resolveRoutePattern(route)
+	conditions, err := compileMatchConditions(route.Match)
+	if err != nil {
+		return err
+	}
 	var lim iRateLimiter
+	var keyed *keyedRateLimiter
 	var traf iTrafficShapeAlgorithm
 
-	if route.LimiterDescriptor != nil {
+	if route.BackendDescriptor != nil {
+		if route.LimiterDescriptor == nil {
+			return errors.New("backend requires a limiter strategy")
+		}
+		factory, err := newRemoteRateLimiterFactory(*route.LimiterDescriptor, *route.BackendDescriptor, backends, closeSign)
+		if err != nil {
+			return err
+		}
+		lim = factory(route.Path)
+		keyed = newKeyedRateLimiter(func(key string) iRateLimiter {
+			return factory(route.Path + "|" + key)
+		}, strategyIdleTTL(*route.LimiterDescriptor))
+	} else if route.LimiterDescriptor != nil {
 		limiter, err := createRateLimiterFromDescriptor(*route.LimiterDescriptor)
 		if err != nil {
 			return err
 		}
 		lim = limiter
+		keyed, err = newStrategyKeyedRateLimiter(*route.LimiterDescriptor)
+		if err != nil {
+			return err
+		}
 	}
 
 	if route.TrafficShaperDescriptor != nil {
@@ -84,47 +256,122 @@ func (r *Router) setupRoute(route RouteDescriptor, closeSign <-chan struct{}) er
 		traf = shapper
 	}
 
-	pipeline := newRequestPipeline(lim, traf)
-	r.setupPath(route.Path, pipeline)
-	return nil
+	pipeline := newKeyedRequestPipeline(lim, keyed, traf)
+	if route.BandwidthDescriptor != nil {
+		bandwidth, err := newKeyedBandwidthLimiter(*route.BandwidthDescriptor)
+		if err != nil {
+			return err
+		}
+		pipeline.bandwidth = bandwidth
+	}
+
+	state := routeState{pipeline: &pipeline}
+	if route.LimiterDescriptor != nil && route.BackendDescriptor == nil {
+		state.limiterFingerprint = descriptorFingerprint(route.LimiterDescriptor)
+	}
+	if route.BandwidthDescriptor != nil {
+		state.bandwidthFingerprint = descriptorFingerprint(route.BandwidthDescriptor)
+	}
+	r.states[key] = state
+
+	path := r.normalization.routePath(route.Path)
+	return r.hostRoot(route.Host).setupPath(path, route.Methods, routeHandler{
+		pipeline:     &pipeline,
+		pattern:      pattern,
+		conditions:   conditions,
+		conditionKey: matchConditionsKey(route.Match),
+		priority:     route.Priority,
+	})
 }
 
-func (r *RouterBuilder) LoadFromJson(jsonData []byte) error {
+// routeConfig is the form of configuration files that set the path
+// normalization along with the routes. Files holding only a list of routes
+// remain valid.
+type routeConfig struct {
+	Normalization *PathNormalization `json:"normalization,omitempty" yaml:"normalization,omitempty"`
+	Routes        []RouteDescriptor  `json:"routes" yaml:"routes"`
+}
 
-	var descriptors []RouteDescriptor
+func (r *RouterBuilder) applyConfig(config routeConfig) {
+	if config.Normalization != nil {
+		r.normalization = *config.Normalization
+	}
+	for _, routeDesc := range config.Routes {
+		r.SetRoute(routeDesc)
+	}
+}
 
-	if err := json.Unmarshal(jsonData, &descriptors); err != nil {
-		return fmt.Errorf("falha ao ler JSON: %w", err)
+// exportConfig returns the list of routes, or a routeConfig when paths are
+// normalized.
+func (r *RouterBuilder) exportConfig() any {
+	if r.normalization == (PathNormalization{}) {
+		return r.GetRouteDescriptors()
+	}
+	return routeConfig{Normalization: &r.normalization, Routes: r.GetRouteDescriptors()}
+}
+
+// LoadFromJson loads a list of routes, or an object with the routes and the
+// normalization of paths.
+func (r *RouterBuilder) LoadFromJson(jsonData []byte) error {
+
+	var config routeConfig
+	var target any = &config.Routes
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) > 0 && trimmed[0] == '{' {
+		target = &config
 	}
 
-	for _, routeDesc := range descriptors {
-		  r.SetRoute(routeDesc);
+	if err := json.Unmarshal(jsonData, target); err != nil {
+		return fmt.Errorf("falha ao ler JSON: %w", err)
 	}
 
+	r.applyConfig(config)
 	return nil
 }
 
+// LoadFromYaml loads a list of routes, or a mapping with the routes and the
+// normalization of paths.
 func (r *RouterBuilder) LoadFromYaml(yamlData []byte) error {
 
-	var descriptors []RouteDescriptor
-
-	if err := yaml.Unmarshal(yamlData, &descriptors); err != nil {
+	var document yaml.Node
+	if err := yaml.Unmarshal(yamlData, &document); err != nil {
 		return fmt.Errorf("falha ao ler YAML: %w", err)
 	}
+	if len(document.Content) == 0 {
+		return nil
+	}
 
-	for _, routeDesc := range descriptors {
-		r.SetRoute(routeDesc)
+	var config routeConfig
+	var target any = &config.Routes
+	if document.Content[0].Kind == yaml.MappingNode {
+		target = &config
+	}
+	if err := document.Content[0].Decode(target); err != nil {
+		return fmt.Errorf("falha ao ler YAML: %w", err)
 	}
 
+	r.applyConfig(config)
 	return nil
 }
 
+// LoadFromFile loads routes from a JSON file, or from YAML for any other
+// extension.
+func (r *RouterBuilder) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".json" {
+		return r.LoadFromJson(data)
+	}
+	return r.LoadFromYaml(data)
+}
+
 func (r *RouterBuilder) ExportToJson() ([]byte, error) {
-	return json.MarshalIndent(r.GetRouteDescriptors(), "", "  ")
+	return json.MarshalIndent(r.exportConfig(), "", "  ")
 }
 
 func (r *RouterBuilder) ExportToYaml() ([]byte, error) {
-	return yaml.Marshal(r.GetRouteDescriptors())
+	return yaml.Marshal(r.exportConfig())
 }
 
 func createTrafficShaperFromDescriptor(strategyDescriptor StrategyDescriptor, closeSign <-chan struct{}) (iTrafficShapeAlgorithm, error) {
@@ -141,6 +388,17 @@ func createTrafficShaperFromDescriptor(strategyDescriptor StrategyDescriptor, cl
 }
 
 func createRateLimiterFromDescriptor(routeLimiterDescriptor StrategyDescriptor) (iRateLimiter, error) {
+	factory, err := newRateLimiterFactory(routeLimiterDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	return factory(), nil
+}
+
+// newRateLimiterFactory parses a strategy once and returns a constructor of
+// limiters applying it, so limiters created on demand, one per key, cannot
+// fail.
+func newRateLimiterFactory(routeLimiterDescriptor StrategyDescriptor) (func() iRateLimiter, error) {
 
 	switch routeLimiterDescriptor.StrategyName {
 	case LimiterStrategyFixedWindow:
@@ -148,21 +406,62 @@ func createRateLimiterFromDescriptor(routeLimiterDescriptor StrategyDescriptor)
 		if err != nil {
 			return nil, err
 		}
-		return newFixedWindowRateLimiter(params.Capacity, params.ResetInterval), nil
+		return func() iRateLimiter {
+			return newFixedWindowRateLimiter(params.Capacity, params.ResetInterval)
+		}, nil
 	case LimiterStrategyTokenBucket:
 		params, err := getTokenBucketRateLimiterParamsFromMap(routeLimiterDescriptor.Params)
 		if err != nil {
 			return nil, err
 		}
-		return newTokenBucketRateLimiter(params.Capacity, params.RefillRate, params.RequestCost), nil
+		return func() iRateLimiter {
+			return newTokenBucketRateLimiter(params.Capacity, params.RefillRate, params.RequestCost)
+		}, nil
 	case LimiterStrategySlidingWindowLog:
 		params, err := getSlidingWindowLogLimiterParamsFromMap(routeLimiterDescriptor.Params)
 		if err != nil {
 			return nil, err
 		}
-		return newSlidingWindowLogLimiter(params.Capacity, params.WindowSize), nil
+		return func() iRateLimiter {
+			return newSlidingWindowLogLimiter(params.Capacity, params.WindowSize)
+		}, nil
+	case LimiterStrategySlidingWindowCounter:
+		params, err := getSlidingWindowCounterLimiterParamsFromMap(routeLimiterDescriptor.Params)
+		if err != nil {
+			return nil, err
+		}
+		return func() iRateLimiter {
+			return newSlidingWindowCounterLimiter(params.Capacity, params.WindowSize)
+		}, nil
 	default:
 		return nil, errors.New("unknown limiter strategy")
 	}
 
 }
+
+// strategyIdleTTL returns how long a limiter of the strategy must stay idle to
+// be back to its initial state, so that dropping it then loses nothing. It
+// returns 0 when the limiter never recovers, as for a token bucket without
+// refill.
+func strategyIdleTTL(strategy StrategyDescriptor) time.Duration {
+	switch strategy.StrategyName {
+	case LimiterStrategyFixedWindow:
+		if params, err := GetFixedWindowRateLimiterParamsFromMap(strategy.Params); err == nil {
+			return params.ResetInterval
+		}
+	case LimiterStrategyTokenBucket:
+		if params, err := getTokenBucketRateLimiterParamsFromMap(strategy.Params); err == nil && params.RefillRate > 0 {
+			return time.Duration(params.Capacity / params.RefillRate * float64(time.Second))
+		}
+	case LimiterStrategySlidingWindowLog:
+		if params, err := getSlidingWindowLogLimiterParamsFromMap(strategy.Params); err == nil {
+			return params.WindowSize
+		}
+	case LimiterStrategySlidingWindowCounter:
+		// The previous window still weighs on the current one
+		if params, err := getSlidingWindowCounterLimiterParamsFromMap(strategy.Params); err == nil {
+			return 2 * params.WindowSize
+		}
+	}
+	return 0
+}
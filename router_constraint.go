@@ -0,0 +1,45 @@
+package rate_limiter
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+)
+
+// segmentTypes are the named constraints of path variables, such as
+// :id<int>. Any other constraint is a regular expression.
+var segmentTypes = map[string]string{
+	"int":   `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[A-Za-z0-9]+`,
+	"hex":   `[0-9A-Fa-f]+`,
+	"uuid":  `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`,
+}
+
+// compileSegmentConstraint returns the expression that a whole segment must
+// match for a constrained variable.
+func compileSegmentConstraint(constraint string) (*regexp.Regexp, error) {
+	expression, typed := segmentTypes[constraint]
+	if !typed {
+		expression = constraint
+	}
+	compiled, err := regexp.Compile(`^(?:` + expression + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint <%s>: %w", constraint, err)
+	}
+	return compiled, nil
+}
+
+// compareConstraints orders the constrained variables of a segment as they
+// are tried: named types first, then regular expressions, each by their text.
+func compareConstraints(a, b string) int {
+	_, aTyped := segmentTypes[a]
+	_, bTyped := segmentTypes[b]
+	if aTyped != bTyped {
+		if aTyped {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Compare(a, b)
+}
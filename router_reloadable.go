@@ -0,0 +1,142 @@
+package rate_limiter
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reloadGracePeriod is how long a replaced router keeps running, so requests
+// queued in its traffic shapers are still released.
+const reloadGracePeriod = time.Minute
+
+type reloadableRouterState struct {
+	router    Router
+	closeChan chan struct{}
+}
+
+// routeState is what a reloaded router takes over from the router it replaces
+// for a route whose limits did not change, so that reloading a configuration
+// does not reset the counters of the route and of its clients. A fingerprint
+// is empty for state that is not taken over: shared backends keep their
+// state themselves, and traffic shapers stop with the router they belong to.
+type routeState struct {
+	limiterFingerprint   string
+	bandwidthFingerprint string
+	pipeline             *requestPipeline
+}
+
+// descriptorFingerprint identifies the configuration of a limiter, or is
+// empty if it cannot be encoded.
+func descriptorFingerprint(descriptor any) string {
+	data, err := json.Marshal(descriptor)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// inheritState makes the routes of r whose limits are the same as in previous
+// share the limiters of previous. r must not serve requests yet.
+func (r *Router) inheritState(previous Router) {
+	for key, state := range r.states {
+		old, exists := previous.states[key]
+		if !exists {
+			continue
+		}
+		if state.limiterFingerprint != "" && state.limiterFingerprint == old.limiterFingerprint {
+			state.pipeline.rateLimiter = old.pipeline.rateLimiter
+			state.pipeline.keyedRateLimiter = old.pipeline.keyedRateLimiter
+		}
+		if state.bandwidthFingerprint != "" && state.bandwidthFingerprint == old.bandwidthFingerprint {
+			state.pipeline.bandwidth = old.pipeline.bandwidth
+		}
+	}
+}
+
+// ReloadableRouter serves requests from a router that can be rebuilt at
+// runtime, for example when its configuration file changes. Replaced routers
+// are closed after a grace period. Routes whose host, path, methods, match
+// conditions and limits are unchanged keep the state of their limiters.
+type ReloadableRouter struct {
+	current atomic.Pointer[reloadableRouterState]
+	build   func(closeSign <-chan struct{}) (Router, error)
+	mutex   sync.Mutex
+	closed  bool
+}
+
+// NewReloadableRouter builds the first router with build, which is called
+// again on every Reload with a fresh close signal.
+func NewReloadableRouter(build func(closeSign <-chan struct{}) (Router, error)) (*ReloadableRouter, error) {
+	reloadable := &ReloadableRouter{
+		build: build,
+		mutex: sync.Mutex{},
+	}
+	if err := reloadable.Reload(); err != nil {
+		return nil, err
+	}
+	return reloadable, nil
+}
+
+// Reload builds a new router and swaps it in. If build fails, the current
+// router is kept.
+func (r *ReloadableRouter) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	closeChan := make(chan struct{})
+	router, err := r.build(closeChan)
+	if err != nil {
+		close(closeChan)
+		return err
+	}
+	if r.closed {
+		close(closeChan)
+		return nil
+	}
+
+	if current := r.current.Load(); current != nil {
+		router.inheritState(current.router)
+	}
+	previous := r.current.Swap(&reloadableRouterState{router: router, closeChan: closeChan})
+	if previous != nil {
+		time.AfterFunc(reloadGracePeriod, func() { close(previous.closeChan) })
+	}
+	return nil
+}
+
+// Close stops the current router.
+func (r *ReloadableRouter) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.current.Load().closeChan)
+}
+
+func (r *ReloadableRouter) Router() Router {
+	return r.current.Load().router
+}
+
+func (r *ReloadableRouter) HandleRequest(method, path string) (RequestPipelineResponse, bool) {
+	return r.current.Load().router.HandleRequest(method, path)
+}
+
+func (r *ReloadableRouter) HandleKeyedRequest(method, path, key string, cost float64) (RequestPipelineResponse, bool) {
+	return r.current.Load().router.HandleKeyedRequest(method, path, key, cost)
+}
+
+func (r *ReloadableRouter) HandleRouteRequest(request RouteRequest, key string, cost float64) (RequestPipelineResponse, bool) {
+	return r.current.Load().router.HandleRouteRequest(request, key, cost)
+}
+
+func (r *ReloadableRouter) MatchRoute(request RouteRequest) (RouteMatch, bool) {
+	return r.current.Load().router.MatchRoute(request)
+}
+
+func (r *ReloadableRouter) BandwidthLimiters(request RouteRequest, key string) (*BandwidthLimiter, *BandwidthLimiter, bool) {
+	return r.current.Load().router.BandwidthLimiters(request, key)
+}
@@ -0,0 +1,84 @@
+package rate_limiter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReloadableRouter_Reload(t *testing.T) {
+	capacity := 1
+	fail := false
+	reloadable, err := NewReloadableRouter(func(closeSign <-chan struct{}) (Router, error) {
+		if fail {
+			return Router{}, errors.New("invalid config")
+		}
+		builder := NewRouterBuilder(closeSign)
+		builder.SetRoute(RouteDescriptor{
+			Path: "/api",
+			LimiterDescriptor: &StrategyDescriptor{
+				StrategyName: LimiterStrategyFixedWindow,
+				Params:       map[string]any{"capacity": capacity, "reset_interval": 60.0},
+			},
+		})
+		return builder.Build()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloadable.Close()
+
+	reloadable.HandleRequest(http.MethodGet, "/api")
+	if resp, _ := reloadable.HandleRequest(http.MethodGet, "/api"); <-resp.Allowed() {
+		t.Fatal("Expected initial capacity of 1")
+	}
+
+	capacity = 5
+	if err := reloadable.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := reloadable.HandleRouteRequest(RouteRequest{Method: http.MethodGet, Path: "/api"}, "", 1); !<-resp.Allowed() || resp.Limit() != 5 {
+		t.Errorf("Expected the reloaded router to apply capacity 5, got limit %v", resp.Limit())
+	}
+
+	fail = true
+	if err := reloadable.Reload(); err == nil {
+		t.Fatal("Expected reload error")
+	}
+	if resp, _ := reloadable.HandleRequest(http.MethodGet, "/api"); resp.Limit() != 5 {
+		t.Error("Expected a failed reload to keep the previous router")
+	}
+}
+
+func TestReloadableRouter_KeepsStateOfUnchangedRoutes(t *testing.T) {
+	usersCapacity := 1
+	reloadable, err := NewReloadableRouter(func(closeSign <-chan struct{}) (Router, error) {
+		builder := NewRouterBuilder(closeSign)
+		builder.SetRoute(newTestFixedWindowRoute("/orders", nil, 1))
+		builder.SetRoute(newTestFixedWindowRoute("/users", nil, usersCapacity))
+		return builder.Build()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloadable.Close()
+
+	for _, path := range []string{"/orders", "/users"} {
+		reloadable.HandleRequest(http.MethodGet, path)
+		reloadable.HandleKeyedRequest(http.MethodGet, path, "client", 1)
+	}
+
+	usersCapacity = 2
+	if err := reloadable.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := reloadable.HandleRequest(http.MethodGet, "/orders"); <-resp.Allowed() {
+		t.Error("Expected the unchanged route to keep its counter across the reload")
+	}
+	if resp, _ := reloadable.HandleKeyedRequest(http.MethodGet, "/orders", "client", 1); <-resp.Allowed() {
+		t.Error("Expected the unchanged route to keep the counters of its clients across the reload")
+	}
+	if resp, _ := reloadable.HandleRequest(http.MethodGet, "/users"); !<-resp.Allowed() || resp.Limit() != 2 {
+		t.Errorf("Expected the changed route to start over with its new limit, got limit %v", resp.Limit())
+	}
+}
@@ -1,6 +1,10 @@
 package rate_limiter
 
 import (
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
 	"testing"
 )
 
@@ -37,28 +41,28 @@ func TestRouter_Priorities(t *testing.T) {
 	builder.SetRoute(varDesc)
 	builder.SetRoute(wildcardDesc)
 
-	router := builder.Build()
+	router := mustBuildRouter(t, &builder)
 
 	// Test Static Match
-	_, found := router.evalRoute("/api/v1/users")
+	_, found := router.evalRoute(RouteRequest{Method: http.MethodGet, Path: "/api/v1/users"})
 	if !found {
 		t.Fatal("Expected to find route /api/v1/users")
 	}
 
 	// Test Var Match
-	_, found = router.evalRoute("/api/v1/123")
+	_, found = router.evalRoute(RouteRequest{Method: http.MethodGet, Path: "/api/v1/123"})
 	if !found {
 		t.Fatal("Expected to find route /api/v1/123")
 	}
 
 	// Test Wildcard Match
-	_, found = router.evalRoute("/api/v1/single-segment")
+	_, found = router.evalRoute(RouteRequest{Method: http.MethodGet, Path: "/api/v1/single-segment"})
 	if !found {
 		t.Fatal("Expected to find route /api/v1/*")
 	}
 
 	// Test No Match
-	_, found = router.evalRoute("/api/v2/users")
+	_, found = router.evalRoute(RouteRequest{Method: http.MethodGet, Path: "/api/v2/users"})
 	if found {
 		t.Fatal("Expected NOT to find route /api/v2/users")
 	}
@@ -88,25 +92,25 @@ func TestRouter_ConflictResolution(t *testing.T) {
 		},
 	})
 
-	router := builder.Build()
+	router := mustBuildRouter(t, &builder)
 
 	// Request /a
 	// Should hit Static (/a) -> Capacity 1.
-	p, found := router.evalRoute("/a")
+	p, found := router.evalRoute(RouteRequest{Method: http.MethodGet, Path: "/a"})
 	if !found {
 		t.Fatal("Route not found")
 	}
 
 	// Check capacity by exhausting it.
 	// 1st request ok
-	resp := p.handleRequest()
+	resp := p.pipeline.handleRequest()
 	<-resp.Allowed()
 
 		// 2nd request blocked (if it was capacity 1)
 
 		// If it matched /:b, capacity would be 100, so it would pass.
 
-		resp = p.handleRequest()
+		resp = p.pipeline.handleRequest()
 
 		if allowed := <-resp.Allowed(); allowed {
 
@@ -206,4 +210,583 @@ func TestRouter_ConflictResolution(t *testing.T) {
 
 	}
 
-	
\ No newline at end of file
+	
+func newTestFixedWindowRoute(path string, methods []string, capacity int) RouteDescriptor {
+	return RouteDescriptor{
+		Path:    path,
+		Methods: methods,
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyFixedWindow,
+			Params:       map[string]any{"capacity": capacity, "reset_interval": 60.0},
+		},
+	}
+}
+
+func TestRouter_MethodRoutes(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/orders", []string{"post"}, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/orders", nil, 10))
+	builder.SetRoute(newTestFixedWindowRoute("/items/:id", []string{http.MethodDelete}, 2))
+	builder.SetRoute(newTestFixedWindowRoute("/items/*", nil, 20))
+	if len(builder.GetRouteDescriptors()) != 4 {
+		t.Fatalf("Expected routes of the same path with different methods to coexist, got %d", len(builder.GetRouteDescriptors()))
+	}
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected float64
+	}{
+		{http.MethodPost, "/orders", 1},
+		{http.MethodGet, "/orders", 10},
+		{"", "/orders", 10},
+		{http.MethodDelete, "/items/1", 2},
+		// The method route does not match GET, so the wildcard route applies
+		{http.MethodGet, "/items/1", 20},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRequest(tt.method, tt.path)
+		if !found {
+			t.Errorf("Expected %s %s to match", tt.method, tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s %s to use the route with capacity %v, got %v", tt.method, tt.path, tt.expected, resp.Limit())
+		}
+	}
+}
+
+func TestRouter_MethodOnlyRoute(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/orders", []string{http.MethodPost, http.MethodPut}, 1))
+	router := mustBuildRouter(t, &builder)
+
+	if _, found := router.HandleRequest(http.MethodPut, "/orders"); !found {
+		t.Error("Expected PUT to match")
+	}
+	if _, found := router.HandleRequest(http.MethodGet, "/orders"); found {
+		t.Error("Expected GET not to match a POST/PUT route")
+	}
+	if _, found := router.HandleRequest("", "/orders"); found {
+		t.Error("Expected an empty method to only match routes for any method")
+	}
+}
+
+func TestRouterBuilder_RemoveMethodRoute(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/orders", []string{http.MethodPost}, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/orders", nil, 10))
+	builder.SetRoute(newTestFixedWindowRoute("/items", nil, 10))
+
+	builder.RemoveRoute("/orders", "POST")
+	if len(builder.GetRouteDescriptors()) != 2 {
+		t.Errorf("Expected only the POST route to be removed, got %d routes", len(builder.GetRouteDescriptors()))
+	}
+	builder.SetRoute(newTestFixedWindowRoute("/orders", []string{http.MethodPost}, 1))
+	builder.RemoveRoute("/orders")
+	if len(builder.GetRouteDescriptors()) != 1 {
+		t.Errorf("Expected every /orders route to be removed, got %d routes", len(builder.GetRouteDescriptors()))
+	}
+}
+
+func TestRouterBuilder_LoadMethods(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	err := builder.LoadFromYaml([]byte(`
+- path: /orders
+  methods: [POST]
+  limiter: {type: fixed_window, params: {capacity: 1, reset_interval: 60}}
+- path: /orders
+  limiter: {type: fixed_window, params: {capacity: 10, reset_interval: 60}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mustBuildRouter(t, &builder)
+	if resp, _ := router.HandleRequest(http.MethodPost, "/orders"); resp.Limit() != 1 {
+		t.Errorf("Expected the POST route, got capacity %v", resp.Limit())
+	}
+}
+
+func TestRouter_HostRoutes(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	exact := newTestFixedWindowRoute("/api/*", nil, 1)
+	exact.Host = "acme.tenants.example.com"
+	wildcard := newTestFixedWindowRoute("/api/*", nil, 2)
+	wildcard.Host = "*.tenants.example.com"
+	wildcardPath := newTestFixedWindowRoute("/reports", nil, 3)
+	wildcardPath.Host = "*.tenants.example.com"
+	builder.SetRoute(exact)
+	builder.SetRoute(wildcard)
+	builder.SetRoute(wildcardPath)
+	builder.SetRoute(newTestFixedWindowRoute("/api/*", nil, 10))
+	builder.SetRoute(newTestFixedWindowRoute("/status", nil, 20))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		host     string
+		path     string
+		expected float64
+	}{
+		{"acme.tenants.example.com", "/api/users", 1},
+		{"ACME.tenants.example.com:8080", "/api/users", 1},
+		{"globex.tenants.example.com", "/api/users", 2},
+		{"eu.globex.tenants.example.com", "/api/users", 2},
+		// The wildcard does not match the apex domain
+		{"tenants.example.com", "/api/users", 10},
+		{"", "/api/users", 10},
+		// Without a route in the host trie, the request falls through
+		{"acme.tenants.example.com", "/reports", 3},
+		{"acme.tenants.example.com", "/status", 20},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRouteRequest(RouteRequest{Host: tt.host, Method: http.MethodGet, Path: tt.path}, "", 1)
+		if !found {
+			t.Errorf("Expected %s%s to match", tt.host, tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s%s to use the route with capacity %v, got %v", tt.host, tt.path, tt.expected, resp.Limit())
+		}
+	}
+	if _, found := router.evalRoute(RouteRequest{Host: "other.example.com", Path: "/reports"}); found {
+		t.Error("Expected a wildcard host route not to match other hosts")
+	}
+}
+
+func TestRouterBuilder_RemoveHostRoute(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	hostRoute := newTestFixedWindowRoute("/api", nil, 1)
+	hostRoute.Host = "api.example.com"
+	builder.SetRoute(hostRoute)
+	builder.SetRoute(newTestFixedWindowRoute("/api", nil, 10))
+	if len(builder.GetRouteDescriptors()) != 2 {
+		t.Fatalf("Expected routes of the same path with different hosts to coexist, got %d", len(builder.GetRouteDescriptors()))
+	}
+
+	builder.RemoveRoute("/api")
+	descriptors := builder.GetRouteDescriptors()
+	if len(descriptors) != 1 || descriptors[0].Host != "api.example.com" {
+		t.Fatalf("Expected only the route for any host to be removed, got %+v", descriptors)
+	}
+	builder.RemoveHostRoute("API.example.com", "/api")
+	if len(builder.GetRouteDescriptors()) != 0 {
+		t.Errorf("Expected the host route to be removed, got %d routes", len(builder.GetRouteDescriptors()))
+	}
+}
+
+func TestRouter_CatchAll(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/static/**", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/static/*", nil, 2))
+	builder.SetRoute(newTestFixedWindowRoute("/files/{path...}", nil, 3))
+	builder.SetRoute(newTestFixedWindowRoute("/files/public/index.html", nil, 4))
+	builder.SetRoute(newTestFixedWindowRoute("/**", nil, 5))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		path     string
+		expected float64
+	}{
+		// A single segment prefers the wildcard
+		{"/static/site.css", 2},
+		{"/static/css/site.css", 1},
+		{"/static", 1},
+		{"/files/a/b/c.txt", 3},
+		{"/files/public/index.html", 4},
+		{"/files/public/other.html", 3},
+		{"/other/path", 5},
+		{"/", 5},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRequest(http.MethodGet, tt.path)
+		if !found {
+			t.Errorf("Expected %s to match", tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s to use the route with capacity %v, got %v", tt.path, tt.expected, resp.Limit())
+		}
+	}
+}
+
+func TestRouter_CatchAllMustBeLast(t *testing.T) {
+	router := newRouter()
+	if err := router.root.setupPath("/files/**/raw", nil, routeHandler{}); err == nil {
+		t.Error("Expected an error for a catch-all before the last segment")
+	}
+	if err := router.root.setupPath("/files/{path...}/raw", nil, routeHandler{}); err == nil {
+		t.Error("Expected an error for a catch-all before the last segment")
+	}
+}
+
+func TestRouter_ServeMuxPatterns(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("POST /items/{id}", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("GET  /items/{id}", nil, 2))
+	builder.SetRoute(newTestFixedWindowRoute("DELETE api.example.com/items/{rest...}", nil, 3))
+	builder.SetRoute(newTestFixedWindowRoute("/{$}", nil, 4))
+	if len(builder.GetRouteDescriptors()) != 4 {
+		t.Fatalf("Expected patterns with different methods to coexist, got %d", len(builder.GetRouteDescriptors()))
+	}
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		host     string
+		method   string
+		path     string
+		expected float64
+	}{
+		{"", http.MethodPost, "/items/1", 1},
+		{"", http.MethodGet, "/items/1", 2},
+		// GET patterns also match HEAD, like in ServeMux
+		{"", http.MethodHead, "/items/1", 2},
+		{"api.example.com", http.MethodDelete, "/items/1/tags", 3},
+		{"", http.MethodGet, "/", 4},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRouteRequest(RouteRequest{Host: tt.host, Method: tt.method, Path: tt.path}, "", 1)
+		if !found {
+			t.Errorf("Expected %s %s%s to match", tt.method, tt.host, tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s %s%s to use the route with capacity %v, got %v", tt.method, tt.host, tt.path, tt.expected, resp.Limit())
+		}
+	}
+	if _, found := router.HandleRequest(http.MethodDelete, "/items/1"); found {
+		t.Error("Expected DELETE not to match the GET and POST patterns")
+	}
+
+	builder.RemoveRoute("POST /items/{id}")
+	if len(builder.GetRouteDescriptors()) != 3 {
+		t.Errorf("Expected the POST pattern to be removed, got %d routes", len(builder.GetRouteDescriptors()))
+	}
+}
+
+func TestRouter_ServeMuxTrailingSlash(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("GET /", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("GET /static/", nil, 2))
+	builder.SetRoute(newTestFixedWindowRoute("/static/site.css", nil, 3))
+	builder.SetRoute(newTestFixedWindowRoute("/a/{$}", nil, 4))
+	builder.SetRoute(newTestFixedWindowRoute("/{$}", nil, 5))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		path     string
+		expected float64
+	}{
+		// A trailing slash matches every path below it
+		{"/foo", 1},
+		{"/static/", 2},
+		{"/static/css/site.css", 2},
+		{"/static/site.css", 3},
+		// {$} only matches the path ending with a slash
+		{"/a/", 4},
+		{"/a", 1},
+		{"/a/b", 1},
+		{"/", 5},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRequest(http.MethodGet, tt.path)
+		if !found {
+			t.Errorf("Expected %s to match", tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s to use the route with capacity %v, got %v", tt.path, tt.expected, resp.Limit())
+		}
+	}
+}
+
+func TestRouter_LegacyTrailingSlash(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	// Without a method or {} segments, a trailing slash does not make a
+	// subtree
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/api/", nil, 2))
+	router := mustBuildRouter(t, &builder)
+
+	for _, path := range []string{"/", "/api", "/api/"} {
+		if _, found := router.HandleRequest(http.MethodGet, path); !found {
+			t.Errorf("Expected %s to match", path)
+		}
+	}
+	for _, path := range []string{"/foo", "/foo/bar", "/api/x", "/api/x/y"} {
+		if _, found := router.HandleRequest(http.MethodGet, path); found {
+			t.Errorf("Expected %s not to match", path)
+		}
+	}
+}
+
+func TestRouter_ServeMuxHeadPrecedence(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("GET /x", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("HEAD /x", nil, 2))
+	builder.SetRoute(newTestFixedWindowRoute("GET /y", nil, 3))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected float64
+	}{
+		{http.MethodGet, "/x", 1},
+		{http.MethodHead, "/x", 2},
+		{http.MethodHead, "/y", 3},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRequest(tt.method, tt.path)
+		if !found {
+			t.Errorf("Expected %s %s to match", tt.method, tt.path)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s %s to use the route with capacity %v, got %v", tt.method, tt.path, tt.expected, resp.Limit())
+		}
+	}
+}
+
+func TestResolveRoutePattern(t *testing.T) {
+	tests := []struct {
+		path    string
+		host    string
+		pattern string
+		methods []string
+	}{
+		{"GET example.com/items/{id}", "example.com", "/items/{id}", []string{"GET", methodHeadOfGet}},
+		{"GET /static/", "", "/static/**", []string{"GET", methodHeadOfGet}},
+		{"/files/{id}/", "", "/files/{id}/**", nil},
+		// Without a method, the pattern is a path
+		{"/static/", "", "/static/", nil},
+		{"api/v1/users", "", "api/v1/users", nil},
+		{"example.com/items", "", "example.com/items", nil},
+		// Spaces in constraints do not make a method
+		{"/users/:id<\\d{1, 3}>", "", "/users/:id<\\d{1, 3}>", nil},
+		{"POST /users/:id<\\d{1, 3}>", "", "/users/:id<\\d{1, 3}>", []string{"POST"}},
+	}
+	for _, tt := range tests {
+		route := resolveRoutePattern(RouteDescriptor{Path: tt.path})
+		if route.Host != tt.host || route.Path != tt.pattern || !slices.Equal(route.Methods, tt.methods) {
+			t.Errorf("Expected %q to resolve to host %q, path %q and methods %v, got %q, %q and %v", tt.path, tt.host, tt.pattern, tt.methods, route.Host, route.Path, route.Methods)
+		}
+	}
+}
+
+func TestRouter_MatchParams(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/:tenant/users/:id", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/:org/*/{rest...}", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("GET /static/**", nil, 1))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		path     string
+		pattern  string
+		expected map[string]string
+	}{
+		{"/acme/users/42", "/:tenant/users/:id", map[string]string{"tenant": "acme", "id": "42"}},
+		// Variables sharing a node keep the names of their own route
+		{"/acme/teams/a/b/c", "/:org/*/{rest...}", map[string]string{"org": "acme", "rest": "a/b/c"}},
+		{"/acme/teams", "/:org/*/{rest...}", map[string]string{"org": "acme", "rest": ""}},
+		{"/static/css/site.css", "GET /static/**", nil},
+	}
+	for _, tt := range tests {
+		match, found := router.MatchRoute(RouteRequest{Method: http.MethodGet, Path: tt.path})
+		if !found {
+			t.Errorf("Expected %s to match", tt.path)
+			continue
+		}
+		if match.Pattern != tt.pattern {
+			t.Errorf("Expected %s to match %s, got %s", tt.path, tt.pattern, match.Pattern)
+		}
+		if !maps.Equal(match.Params, tt.expected) {
+			t.Errorf("Expected %s to capture %v, got %v", tt.path, tt.expected, match.Params)
+		}
+	}
+
+	resp, _ := router.HandleRequest(http.MethodGet, "/acme/users/42")
+	if resp.Pattern() != "/:tenant/users/:id" || resp.Params()["id"] != "42" {
+		t.Errorf("Expected the response to describe the matched route, got %q %v", resp.Pattern(), resp.Params())
+	}
+}
+
+func mustBuildRouter(t *testing.T, builder *RouterBuilder) Router {
+	t.Helper()
+	router, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRouter_ConstrainedVariables(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	builder.SetRoute(newTestFixedWindowRoute("/users/:id<int>", nil, 1))
+	builder.SetRoute(newTestFixedWindowRoute("/users/:id<uuid>", nil, 2))
+	builder.SetRoute(newTestFixedWindowRoute("/users/:name", nil, 3))
+	builder.SetRoute(newTestFixedWindowRoute(`/files/:name<[a-z0-9-]+\.pdf>`, nil, 4))
+	builder.SetRoute(newTestFixedWindowRoute(`/files/:name<[a-z]+\..+>`, nil, 5))
+	builder.SetRoute(newTestFixedWindowRoute("/files/*", nil, 6))
+	builder.SetRoute(newTestFixedWindowRoute("/orders/:id<int>/items", nil, 7))
+	builder.SetRoute(newTestFixedWindowRoute("/orders/*/**", nil, 8))
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		path     string
+		expected float64
+		params   map[string]string
+	}{
+		{"/users/42", 1, map[string]string{"id": "42"}},
+		{"/users/3f2504e0-4f89-11d3-9a0c-0305e82c3301", 2, map[string]string{"id": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"}},
+		// Non-matching segments fall through to the unconstrained variable
+		{"/users/42abc", 3, map[string]string{"name": "42abc"}},
+		// Constraints match the whole segment
+		{"/files/report-2024.pdf", 4, map[string]string{"name": "report-2024.pdf"}},
+		// Regular expressions are tried in the order of their text
+		{"/files/report.pdf", 4, map[string]string{"name": "report.pdf"}},
+		{"/files/report.txt", 5, map[string]string{"name": "report.txt"}},
+		{"/files/Report.pdf", 6, nil},
+		{"/orders/1/items", 7, map[string]string{"id": "1"}},
+		// A constrained variable whose subtree does not match falls through
+		{"/orders/1/notes", 8, nil},
+	}
+	for _, tt := range tests {
+		match, found := router.MatchRoute(RouteRequest{Method: http.MethodGet, Path: tt.path})
+		if !found {
+			t.Errorf("Expected %s to match", tt.path)
+			continue
+		}
+		resp := match.Handle("", 1)
+		if resp.Limit() != tt.expected {
+			t.Errorf("Expected %s to use the route with capacity %v, got %v (%s)", tt.path, tt.expected, resp.Limit(), match.Pattern)
+		}
+		if !maps.Equal(match.Params, tt.params) {
+			t.Errorf("Expected %s to capture %v, got %v", tt.path, tt.params, match.Params)
+		}
+	}
+}
+
+func TestRouter_InvalidConstraint(t *testing.T) {
+	router := newRouter()
+	if err := router.root.setupPath("/users/:id<[0-9>", nil, routeHandler{}); err == nil {
+		t.Error("Expected an error for an invalid regular expression")
+	}
+	// The slash splits the constraint across two segments
+	if err := router.root.setupPath("/files/:name<[a-z]+/[a-z]+>", nil, routeHandler{}); err == nil {
+		t.Error("Expected an error for a constraint containing a slash")
+	}
+}
+
+func TestRouter_MatchConditions(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	export := newTestFixedWindowRoute("/reports/:id", nil, 1)
+	export.Match = []MatchCondition{{Query: "format", Equals: "pdf"}}
+	mobileExport := newTestFixedWindowRoute("/reports/:id", nil, 2)
+	mobileExport.Match = []MatchCondition{{Query: "format", Equals: "pdf"}, {Header: "X-Client", Equals: "mobile"}}
+	mobile := newTestFixedWindowRoute("/reports/:id", nil, 3)
+	mobile.Match = []MatchCondition{{Header: "X-Client", Equals: "mobile"}}
+	mobile.Priority = 10
+	upload := newTestFixedWindowRoute("/reports/:id", []string{http.MethodPost}, 4)
+	upload.Match = []MatchCondition{{ContentType: "text/csv"}}
+	builder.SetRoute(export)
+	builder.SetRoute(mobileExport)
+	builder.SetRoute(mobile)
+	builder.SetRoute(upload)
+	builder.SetRoute(newTestFixedWindowRoute("/reports/:id", nil, 10))
+	if len(builder.GetRouteDescriptors()) != 5 {
+		t.Fatalf("Expected routes with different conditions to coexist, got %d", len(builder.GetRouteDescriptors()))
+	}
+	router := mustBuildRouter(t, &builder)
+
+	tests := []struct {
+		name     string
+		request  RouteRequest
+		expected float64
+	}{
+		{"query", RouteRequest{Method: http.MethodGet, Path: "/reports/1", Query: url.Values{"format": {"pdf"}}}, 1},
+		// The priority wins over the number of conditions
+		{"priority", RouteRequest{Method: http.MethodGet, Path: "/reports/1", Query: url.Values{"format": {"pdf"}}, Header: http.Header{"X-Client": {"mobile"}}}, 3},
+		{"method", RouteRequest{Method: http.MethodPost, Path: "/reports/1", Header: http.Header{"Content-Type": {"text/csv"}}}, 4},
+		// A method route whose conditions fail falls back to routes for any method
+		{"method fallback", RouteRequest{Method: http.MethodPost, Path: "/reports/1", Query: url.Values{"format": {"pdf"}}}, 1},
+		{"no conditions", RouteRequest{Method: http.MethodGet, Path: "/reports/1", Query: url.Values{"format": {"csv"}}}, 10},
+	}
+	for _, tt := range tests {
+		resp, found := router.HandleRouteRequest(tt.request, "", 1)
+		if !found {
+			t.Errorf("%s: expected a match", tt.name)
+			continue
+		}
+		if resp.Limit() != tt.expected {
+			t.Errorf("%s: expected the route with capacity %v, got %v", tt.name, tt.expected, resp.Limit())
+		}
+	}
+
+	mobile.Priority = 0
+	builder.SetRoute(mobile)
+	router = mustBuildRouter(t, &builder)
+	request := RouteRequest{Method: http.MethodGet, Path: "/reports/1", Query: url.Values{"format": {"pdf"}}, Header: http.Header{"X-Client": {"mobile"}}}
+	if resp, _ := router.HandleRouteRequest(request, "", 1); resp.Limit() != 2 {
+		t.Errorf("Expected the route with the most conditions to win at equal priority, got capacity %v", resp.Limit())
+	}
+}
+
+func TestRouter_MatchConditionsFallThrough(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	builder := NewRouterBuilder(closeChan)
+	mobile := newTestFixedWindowRoute("/api/users", nil, 1)
+	mobile.Match = []MatchCondition{{Header: "X-Client", Equals: "mobile"}}
+	builder.SetRoute(mobile)
+	builder.SetRoute(newTestFixedWindowRoute("/api/*", nil, 2))
+	router := mustBuildRouter(t, &builder)
+
+	if resp, _ := router.HandleRouteRequest(RouteRequest{Path: "/api/users"}, "", 1); resp.Limit() != 2 {
+		t.Errorf("Expected a path whose conditions fail to fall through, got capacity %v", resp.Limit())
+	}
+}
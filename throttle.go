@@ -0,0 +1,118 @@
+package rate_limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ThrottleAnyKey is the key of the descriptor applied to keys without their
+// own descriptor. Each such key gets its own limiter.
+const ThrottleAnyKey = "*"
+
+// ThrottleDescriptor configures the limits of one kind of task, such as
+// "emails" or "webhooks", with the same strategies as routes.
+type ThrottleDescriptor struct {
+	Key                     string              `json:"key" yaml:"key"`
+	LimiterDescriptor       *StrategyDescriptor `json:"limiter,omitempty" yaml:"limiter,omitempty"`
+	TrafficShaperDescriptor *StrategyDescriptor `json:"traffic,omitempty" yaml:"traffic,omitempty"`
+}
+
+// Throttle paces tasks that are not HTTP requests, such as sent emails,
+// fired webhooks or migration batches, by arbitrary string keys. Callers
+// over the limit wait instead of failing. A Throttle is safe for concurrent
+// use.
+type Throttle struct {
+	pipelines map[string]requestPipeline
+}
+
+func NewThrottle(descriptors []ThrottleDescriptor, closeSign <-chan struct{}) (*Throttle, error) {
+	throttle := &Throttle{
+		pipelines: make(map[string]requestPipeline),
+	}
+	for _, descriptor := range descriptors {
+		if descriptor.Key == "" {
+			return nil, errors.New("throttle key must not be empty")
+		}
+		if _, exists := throttle.pipelines[descriptor.Key]; exists {
+			return nil, fmt.Errorf("duplicate throttle key: %s", descriptor.Key)
+		}
+		pipeline, err := newThrottlePipeline(descriptor, closeSign)
+		if err != nil {
+			return nil, fmt.Errorf("throttle %s: %w", descriptor.Key, err)
+		}
+		throttle.pipelines[descriptor.Key] = pipeline
+	}
+	return throttle, nil
+}
+
+func NewThrottleFromJson(jsonData []byte, closeSign <-chan struct{}) (*Throttle, error) {
+	var descriptors []ThrottleDescriptor
+	if err := json.Unmarshal(jsonData, &descriptors); err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %w", err)
+	}
+	return NewThrottle(descriptors, closeSign)
+}
+
+func NewThrottleFromYaml(yamlData []byte, closeSign <-chan struct{}) (*Throttle, error) {
+	var descriptors []ThrottleDescriptor
+	if err := yaml.Unmarshal(yamlData, &descriptors); err != nil {
+		return nil, fmt.Errorf("failed to read YAML: %w", err)
+	}
+	return NewThrottle(descriptors, closeSign)
+}
+
+// Wait blocks until a task of key is allowed, or returns the context error
+// if ctx is done first. Keys without a descriptor, when there is no
+// ThrottleAnyKey descriptor either, are never throttled.
+func (t *Throttle) Wait(ctx context.Context, key string) error {
+	return waitAllowed(ctx, func() (RequestPipelineResponse, bool) {
+		if pipeline, exists := t.pipelines[key]; exists {
+			return pipeline.handleRequest(), true
+		}
+		if pipeline, exists := t.pipelines[ThrottleAnyKey]; exists {
+			return pipeline.handleKeyedRequest(key, 1), true
+		}
+		return RequestPipelineResponse{}, false
+	})
+}
+
+// Do runs fn once a task of key is allowed. fn is not run if ctx is done
+// first, in which case the context error is returned.
+func (t *Throttle) Do(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	if err := t.Wait(ctx, key); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+func newThrottlePipeline(descriptor ThrottleDescriptor, closeSign <-chan struct{}) (requestPipeline, error) {
+	var lim iRateLimiter
+	var keyed *keyedRateLimiter
+	var traf iTrafficShapeAlgorithm
+
+	if descriptor.LimiterDescriptor != nil {
+		limiter, err := createRateLimiterFromDescriptor(*descriptor.LimiterDescriptor)
+		if err != nil {
+			return requestPipeline{}, err
+		}
+		lim = limiter
+		keyed, err = newStrategyKeyedRateLimiter(*descriptor.LimiterDescriptor)
+		if err != nil {
+			return requestPipeline{}, err
+		}
+	}
+
+	if descriptor.TrafficShaperDescriptor != nil {
+		shaper, err := createTrafficShaperFromDescriptor(*descriptor.TrafficShaperDescriptor, closeSign)
+		if err != nil {
+			return requestPipeline{}, err
+		}
+		traf = shaper
+	}
+
+	return newKeyedRequestPipeline(lim, keyed, traf), nil
+}
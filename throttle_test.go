@@ -0,0 +1,149 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThrottle_WaitsForTokens(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	throttle, err := NewThrottle([]ThrottleDescriptor{{
+		Key: "emails",
+		LimiterDescriptor: &StrategyDescriptor{
+			StrategyName: LimiterStrategyTokenBucket,
+			Params:       map[string]any{"capacity": 1, "refill_rate": 20, "request_cost": 1},
+		},
+	}}, closeChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	sent := 0
+	for i := 0; i < 3; i++ {
+		err := throttle.Do(context.Background(), "emails", func(ctx context.Context) error {
+			sent++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent != 3 {
+		t.Errorf("Expected every task to run, got %d", sent)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected tasks to be spaced by the refill rate, took %v", elapsed)
+	}
+
+	// Unconfigured keys are not throttled
+	for i := 0; i < 10; i++ {
+		if err := throttle.Wait(context.Background(), "reports"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestThrottle_ContextCancelsWait(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	throttle, err := NewThrottleFromYaml([]byte(`
+- key: webhooks
+  limiter:
+    type: fixed_window
+    params:
+      capacity: 1
+      reset_interval: 60
+`), closeChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := throttle.Wait(context.Background(), "webhooks"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ran := false
+	err = throttle.Do(ctx, "webhooks", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	if ran {
+		t.Error("Expected the task not to run")
+	}
+}
+
+func TestThrottle_AnyKeyHasBucketPerKey(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	throttle, err := NewThrottleFromJson([]byte(`[{
+		"key": "*",
+		"limiter": {"type": "fixed_window", "params": {"capacity": 1, "reset_interval": 60}}
+	}]`), closeChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(ctx, "customer-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Wait(ctx, "customer-b"); err != nil {
+		t.Errorf("Expected another key to have its own bucket, got %v", err)
+	}
+	if err := throttle.Wait(ctx, "customer-a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the first key to be throttled, got %v", err)
+	}
+}
+
+func TestThrottle_ShaperDelaysTasks(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	throttle, err := NewThrottle([]ThrottleDescriptor{{
+		Key: "batches",
+		TrafficShaperDescriptor: &StrategyDescriptor{
+			StrategyName: TrafficStrategyLeakyBucket,
+			Params:       map[string]any{"capacity": 10, "drop_per_second": 20},
+		},
+	}}, closeChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := throttle.Wait(context.Background(), "batches"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected tasks to be released at the drop rate, took %v", elapsed)
+	}
+}
+
+func TestNewThrottle_InvalidDescriptors(t *testing.T) {
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+
+	if _, err := NewThrottle([]ThrottleDescriptor{{Key: ""}}, closeChan); err == nil {
+		t.Error("Expected an error for an empty key")
+	}
+	if _, err := NewThrottle([]ThrottleDescriptor{{Key: "a"}, {Key: "a"}}, closeChan); err == nil {
+		t.Error("Expected an error for a duplicate key")
+	}
+	if _, err := NewThrottle([]ThrottleDescriptor{{
+		Key:               "a",
+		LimiterDescriptor: &StrategyDescriptor{StrategyName: "unknown"},
+	}}, closeChan); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,172 @@
+package rate_limiter
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// upstreamQuota is the state of a server-side limit as reported by response
+// headers. Fields that were not reported are zero; hasLimit and hasReset tell
+// them apart from reported zeros.
+type upstreamQuota struct {
+	limit     float64
+	remaining float64
+	reset     time.Duration
+	window    time.Duration
+	hasLimit  bool
+	hasReset  bool
+}
+
+// parseUpstreamQuota reads the quota reported by an upstream, trying in order
+// the structured RateLimit header (limit=10, remaining=5, reset=30 or
+// r=5;t=30), the RateLimit-Limit/Remaining/Reset fields and the common
+// X-RateLimit-* headers. Resets larger than a Unix timestamp in 2001 are
+// treated as absolute times. It reports false when no remaining count is
+// present.
+func parseUpstreamQuota(header http.Header, now time.Time) (upstreamQuota, bool) {
+	quota, ok := parseStructuredRateLimit(header.Get("RateLimit"), now)
+	if !ok {
+		quota, ok = parseRateLimitFields(header, "RateLimit-", now)
+	}
+	if !ok {
+		quota, ok = parseRateLimitFields(header, "X-RateLimit-", now)
+	}
+	if !ok {
+		return upstreamQuota{}, false
+	}
+	if policy := header.Get("RateLimit-Policy"); policy != "" {
+		params := parseHeaderParams(policy)
+		if seconds, ok := params["w"]; ok && seconds > 0 {
+			quota.window = secondsDuration(seconds)
+		}
+		if limit, ok := params["q"]; ok && !quota.hasLimit {
+			quota.limit, quota.hasLimit = limit, true
+		}
+	}
+	return quota, true
+}
+
+func parseStructuredRateLimit(value string, now time.Time) (upstreamQuota, bool) {
+	if value == "" {
+		return upstreamQuota{}, false
+	}
+	params := parseHeaderParams(value)
+	var quota upstreamQuota
+	remaining, ok := params["remaining"]
+	if !ok {
+		remaining, ok = params["r"]
+	}
+	if !ok {
+		return upstreamQuota{}, false
+	}
+	quota.remaining = remaining
+	if limit, ok := params["limit"]; ok {
+		quota.limit, quota.hasLimit = limit, true
+	}
+	reset, ok := params["reset"]
+	if !ok {
+		reset, ok = params["t"]
+	}
+	if ok {
+		quota.reset, quota.hasReset = parseResetSeconds(reset, now), true
+	}
+	return quota, true
+}
+
+func parseRateLimitFields(header http.Header, prefix string, now time.Time) (upstreamQuota, bool) {
+	remaining, ok := parseLeadingNumber(header.Get(prefix + "Remaining"))
+	if !ok {
+		return upstreamQuota{}, false
+	}
+	quota := upstreamQuota{remaining: remaining}
+	if limit, ok := parseLeadingNumber(header.Get(prefix + "Limit")); ok {
+		quota.limit, quota.hasLimit = limit, true
+		// RateLimit-Limit may carry the policy, as in "100, 100;w=60"
+		if seconds, ok := parseHeaderParams(header.Get(prefix + "Limit"))["w"]; ok && seconds > 0 {
+			quota.window = secondsDuration(seconds)
+		}
+	}
+	if reset, ok := parseLeadingNumber(header.Get(prefix + "Reset")); ok {
+		quota.reset, quota.hasReset = parseResetSeconds(reset, now), true
+	}
+	return quota, true
+}
+
+// parseRetryAfter reads a Retry-After value given either in seconds or as an
+// HTTP date. Negative and non-finite seconds are ignored; callers bound the
+// delay they are willing to wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, false
+		}
+		return secondsDuration(seconds), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseResetSeconds turns a reset value into a delay. Upstreams disagree on
+// whether it is a delay or a Unix timestamp, in seconds or milliseconds.
+func parseResetSeconds(reset float64, now time.Time) time.Duration {
+	switch {
+	case reset > 1e12:
+		return max(time.UnixMilli(int64(reset)).Sub(now), 0)
+	case reset > 1e9:
+		return max(time.Unix(int64(reset), 0).Sub(now), 0)
+	default:
+		return secondsDuration(max(reset, 0))
+	}
+}
+
+// parseHeaderParams collects the numeric key=value parameters of a header,
+// separated by commas or semicolons. Later parameters do not override
+// earlier ones, so the first policy of a list wins.
+func parseHeaderParams(value string) map[string]float64 {
+	params := make(map[string]float64)
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, raw, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if _, exists := params[key]; exists {
+			continue
+		}
+		if number, ok := parseLeadingNumber(strings.Trim(strings.TrimSpace(raw), `"`)); ok {
+			params[key] = number
+		}
+	}
+	return params
+}
+
+// parseLeadingNumber reads the number at the start of a header value, before
+// any list or parameter separator.
+func parseLeadingNumber(value string) (float64, bool) {
+	if index := strings.IndexAny(value, ",;"); index >= 0 {
+		value = value[:index]
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// secondsDuration converts seconds to a Duration, saturating instead of
+// overflowing for values reported by upstreams.
+func secondsDuration(seconds float64) time.Duration {
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
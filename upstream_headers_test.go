@@ -0,0 +1,98 @@
+package rate_limiter
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreamQuota(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		header    map[string]string
+		limit     float64
+		remaining float64
+		reset     time.Duration
+		window    time.Duration
+	}{
+		{
+			name:      "structured",
+			header:    map[string]string{"RateLimit": "limit=100, remaining=40, reset=30"},
+			limit:     100,
+			remaining: 40,
+			reset:     30 * time.Second,
+		},
+		{
+			name:      "structured with policy",
+			header:    map[string]string{"RateLimit": `"default";r=5;t=10`, "RateLimit-Policy": `"default";q=50;w=60`},
+			limit:     50,
+			remaining: 5,
+			reset:     10 * time.Second,
+			window:    time.Minute,
+		},
+		{
+			name:      "fields",
+			header:    map[string]string{"RateLimit-Limit": "10, 10;w=1", "RateLimit-Remaining": "3", "RateLimit-Reset": "0.5"},
+			limit:     10,
+			remaining: 3,
+			reset:     500 * time.Millisecond,
+			window:    time.Second,
+		},
+		{
+			name:      "x-ratelimit with epoch reset",
+			header:    map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999", "X-RateLimit-Reset": "1700000060"},
+			limit:     5000,
+			remaining: 4999,
+			reset:     time.Minute,
+		},
+		{
+			name:      "x-ratelimit with millisecond reset",
+			header:    map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000002000"},
+			remaining: 0,
+			reset:     2 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			quota, ok := parseUpstreamQuota(header, now)
+			if !ok {
+				t.Fatal("Expected a quota to be parsed")
+			}
+			if quota.limit != tt.limit || quota.remaining != tt.remaining || quota.reset != tt.reset || quota.window != tt.window {
+				t.Errorf("Expected limit %v remaining %v reset %v window %v, got %+v", tt.limit, tt.remaining, tt.reset, tt.window, quota)
+			}
+		})
+	}
+
+	if _, ok := parseUpstreamQuota(http.Header{"Ratelimit-Limit": {"10"}}, now); ok {
+		t.Error("Expected no quota without a remaining count")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	if delay, ok := parseRetryAfter("2", now); !ok || delay != 2*time.Second {
+		t.Errorf("Expected 2s, got %v %v", delay, ok)
+	}
+	if delay, ok := parseRetryAfter(now.Add(30*time.Second).UTC().Format(http.TimeFormat), now); !ok || delay != 30*time.Second {
+		t.Errorf("Expected 30s from an HTTP date, got %v %v", delay, ok)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("Expected an invalid value to be ignored")
+	}
+	for _, value := range []string{"NaN", "Inf", "-Inf", "-1"} {
+		if _, ok := parseRetryAfter(value, now); ok {
+			t.Errorf("Expected %s to be ignored", value)
+		}
+	}
+	if delay, ok := parseRetryAfter("1e300", now); !ok || delay != math.MaxInt64 {
+		t.Errorf("Expected a huge delay to saturate instead of overflowing, got %v %v", delay, ok)
+	}
+}